@@ -0,0 +1,217 @@
+// Package brick is a client of the Paymentwall Brick Direct API, which charges cards
+// from the server.
+// https://docs.paymentwall.com/integration/direct
+package brick
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sanae10001/paymentwall-go"
+)
+
+const DefaultBaseURL = "https://api.paymentwall.com/api/brick"
+
+// NewClient returns a Client of the project with the given public and private keys.
+func NewClient(publicKey, privateKey string) *Client {
+	return &Client{
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+		BaseURL:    DefaultBaseURL,
+	}
+}
+
+type Client struct {
+	PublicKey  string
+	PrivateKey string
+
+	// BaseURL is DefaultBaseURL unless the client is pointed at a stand-in, e.g. a httptest.Server.
+	BaseURL string
+	// HTTPClient sends the requests, http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// Card holds the card details exchanged for a one-time token.
+type Card struct {
+	Number   string
+	ExpMonth string
+	ExpYear  string
+	CVV      string
+}
+
+// Token is a one-time token standing for a card.
+type Token struct {
+	Token     string   `json:"token"`
+	Test      flexBool `json:"test"`
+	ExpiresIn int      `json:"expires_in"` // Seconds
+}
+
+// CreateToken exchanges card details for a one-time token. Tokens are usually created
+// in the browser by Brick.js, so card details never reach the server.
+func (c *Client) CreateToken(ctx context.Context, card Card) (*Token, error) {
+	form := url.Values{
+		"public_key":      {c.PublicKey},
+		"card[number]":    {card.Number},
+		"card[exp_month]": {card.ExpMonth},
+		"card[exp_year]":  {card.ExpYear},
+		"card[cvv]":       {card.CVV},
+	}
+	var token Token
+	if err := c.post(ctx, "/token", form, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+type ChargeRequest struct {
+	Token       string // One-time token of the card
+	Amount      paymentwall.Money
+	Email       string
+	Fingerprint string // Browser fingerprint collected by Brick.js
+	Description string
+
+	// SecureRedirectURL is where users return after 3-D Secure, see SecureHandler.
+	SecureRedirectURL string
+	// SecureToken and ChargeID complete a charge after 3-D Secure, see CompleteSecureCharge.
+	SecureToken string
+	ChargeID    string
+
+	// Extra holds additional parameters, such as customer[firstname] or uid.
+	Extra url.Values
+}
+
+func (r *ChargeRequest) form() url.Values {
+	form := url.Values{
+		"token":       {r.Token},
+		"amount":      {r.Amount.Decimal()},
+		"currency":    {r.Amount.Currency},
+		"email":       {r.Email},
+		"fingerprint": {r.Fingerprint},
+		"description": {r.Description},
+	}
+	if r.SecureRedirectURL != "" {
+		form.Set("secure_redirect_url", r.SecureRedirectURL)
+	}
+	if r.SecureToken != "" {
+		form.Set("secure_token", r.SecureToken)
+		form.Set("charge_id", r.ChargeID)
+	}
+	for k, v := range r.Extra {
+		form[k] = v
+	}
+	return form
+}
+
+type Charge struct {
+	ID       string      `json:"id"`
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+	Created  int64       `json:"created"` // Unix time
+	Card     struct {
+		Type     string      `json:"type"`
+		Last4    string      `json:"last4"`
+		ExpMonth json.Number `json:"exp_month"`
+		ExpYear  json.Number `json:"exp_year"`
+	} `json:"card"`
+	Captured flexBool `json:"captured"`
+	Refunded flexBool `json:"refunded"`
+	Risk     string   `json:"risk"`
+	Test     flexBool `json:"test"`
+}
+
+// Money returns the amount of the charge.
+func (c *Charge) Money() (paymentwall.Money, error) {
+	return paymentwall.ParseMoney(c.Amount.String(), c.Currency)
+}
+
+// CreateCharge charges the card of a one-time token. When the card issuer requires
+// 3-D Secure, the error is a *SecureRequired.
+func (c *Client) CreateCharge(ctx context.Context, req ChargeRequest) (*Charge, error) {
+	var charge Charge
+	if err := c.post(ctx, "/charge", req.form(), &charge); err != nil {
+		return nil, err
+	}
+	return &charge, nil
+}
+
+// Capture captures a charge that was only authorized.
+func (c *Client) Capture(ctx context.Context, chargeID string) (*Charge, error) {
+	return c.chargeAction(ctx, chargeID, "capture")
+}
+
+// Void cancels the authorization of a charge that has not been captured.
+func (c *Client) Void(ctx context.Context, chargeID string) (*Charge, error) {
+	return c.chargeAction(ctx, chargeID, "void")
+}
+
+// Refund refunds a captured charge.
+func (c *Client) Refund(ctx context.Context, chargeID string) (*Charge, error) {
+	return c.chargeAction(ctx, chargeID, "refund")
+}
+
+func (c *Client) chargeAction(ctx context.Context, chargeID, action string) (*Charge, error) {
+	var charge Charge
+	path := "/charge/" + url.PathEscape(chargeID) + "/" + action
+	if err := c.post(ctx, path, url.Values{}, &charge); err != nil {
+		return nil, err
+	}
+	return &charge, nil
+}
+
+// post sends form to path and decodes the JSON response into v, or into an *Error
+// or a *SecureRequired.
+func (c *Client) post(ctx context.Context, path string, form url.Values, v interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(c.BaseURL, "/")+path,
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-ApiKey", c.PrivateKey)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := decodeError(resp.StatusCode, body); err != nil {
+		return err
+	}
+	if err := decodeSecure(body); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("brick: decode response of %s: %v", path, err)
+	}
+	return nil
+}
+
+// flexBool decodes the booleans of Brick responses, sent either as JSON booleans or as 0 and 1.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true", "1":
+		*b = true
+	case "false", "0", "", "null":
+		*b = false
+	default:
+		return fmt.Errorf("brick: invalid boolean %s", data)
+	}
+	return nil
+}
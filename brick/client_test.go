@@ -0,0 +1,97 @@
+package brick
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sanae10001/paymentwall-go"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c := NewClient("public", "private")
+	c.BaseURL = server.URL
+	c.HTTPClient = server.Client()
+	return c
+}
+
+func TestClient_CreateCharge(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/charge" || r.Header.Get("X-ApiKey") != "private" {
+			t.Errorf("got %s with key %q", r.URL.Path, r.Header.Get("X-ApiKey"))
+		}
+		if r.FormValue("token") != "ot_1" || r.FormValue("amount") != "19.99" || r.FormValue("currency") != "USD" {
+			t.Errorf("got form %v", r.Form)
+		}
+		fmt.Fprint(w, `{"object":"charge","id":"ch_1","amount":19.99,"currency":"USD","created":1500000000,
+			"card":{"type":"Visa","last4":"4242","exp_month":"12","exp_year":2030},"captured":1,"refunded":false,"risk":"approved","test":1}`)
+	})
+
+	charge, err := c.CreateCharge(context.Background(), ChargeRequest{
+		Token:  "ot_1",
+		Amount: paymentwall.MustParseMoney("19.99", "USD"),
+		Email:  "user@example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if charge.ID != "ch_1" || !bool(charge.Captured) || bool(charge.Refunded) || charge.Card.Last4 != "4242" {
+		t.Errorf("got %+v", charge)
+	}
+	if m, err := charge.Money(); err != nil || m != paymentwall.NewMoney(1999, "USD") {
+		t.Errorf("Money() = %v, %v", m, err)
+	}
+}
+
+func TestClient_ChargeActions(t *testing.T) {
+	var paths []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		fmt.Fprint(w, `{"object":"charge","id":"ch_1"}`)
+	})
+	ctx := context.Background()
+	for _, action := range []func(context.Context, string) (*Charge, error){c.Capture, c.Void, c.Refund} {
+		if _, err := action(ctx, "ch_1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"/charge/ch_1/capture", "/charge/ch_1/void", "/charge/ch_1/refund"}
+	if fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", paths, want)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"type":"Error","object":"Error","error":"Please enter a valid card number","code":3003}`)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, `<html>bad gateway</html>`)
+		}
+	})
+
+	_, err := c.CreateToken(context.Background(), Card{Number: "1"})
+	var brickErr *Error
+	if !errors.As(err, &brickErr) || brickErr.Code != 3003 || brickErr.StatusCode != http.StatusBadRequest ||
+		brickErr.Message != "Please enter a valid card number" {
+		t.Errorf("got %#v", err)
+	}
+
+	if _, err := c.Capture(context.Background(), "ch_1"); !errors.Is(err, ErrorUnexpectedResponse) {
+		t.Errorf("got %v, want %v", err, ErrorUnexpectedResponse)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.Refund(ctx, "ch_1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,44 @@
+package brick
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var ErrorUnexpectedResponse = errors.New("brick: unexpected response")
+
+// Error is an error returned by the Brick API.
+type Error struct {
+	StatusCode int    // HTTP status of the response
+	Code       int    `json:"code"`
+	Message    string `json:"error"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("brick: %s (code %d)", e.Message, e.Code)
+}
+
+// errorResponse is the JSON body of Brick errors:
+//
+//	{"type": "Error", "object": "Error", "error": "Please enter a valid card number", "code": 3003}
+type errorResponse struct {
+	Type   string `json:"type"`
+	Object string `json:"object"`
+	Error
+}
+
+// decodeError returns the error described by a response, or nil for successful responses.
+func decodeError(statusCode int, body []byte) error {
+	var resp errorResponse
+	jsonErr := json.Unmarshal(body, &resp)
+	if jsonErr == nil && (resp.Type == "Error" || resp.Object == "Error") {
+		resp.Error.StatusCode = statusCode
+		return &resp.Error
+	}
+	if statusCode >= http.StatusBadRequest || jsonErr != nil {
+		return fmt.Errorf("%w: status %d: %.200s", ErrorUnexpectedResponse, statusCode, body)
+	}
+	return nil
+}
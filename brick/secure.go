@@ -0,0 +1,140 @@
+package brick
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// SecureRequired is returned as the error of CreateCharge when the card issuer requires
+// a 3-D Secure step. Show the user the form returned by HTML, which sends them to the issuer and
+// then back to ChargeRequest.SecureRedirectURL, where a SecureHandler completes the charge.
+// The response does not identify the charge, so save the ChargeRequest under an ID of the
+// application, such as an order ID, that is also a parameter of SecureRedirectURL.
+type SecureRequired struct {
+	RedirectURL string     // Action of the form
+	Fields      url.Values // Hidden fields of the form
+	FormHTML    string     // Form as sent by Brick
+}
+
+func (e *SecureRequired) Error() string {
+	return "brick: 3-D Secure is required"
+}
+
+var secureTemplate = template.Must(template.New("secure").Parse(`<form id="brick-secure" action="{{.RedirectURL}}" method="POST">
+{{range $name, $values := .Fields}}{{range $values}}<input type="hidden" name="{{$name}}" value="{{.}}">
+{{end}}{{end}}<noscript><button type="submit">Continue</button></noscript>
+</form>
+<script>document.getElementById("brick-secure").submit();</script>`))
+
+// HTML returns a form that sends the user to the 3-D Secure page as soon as it is loaded.
+func (e *SecureRequired) HTML() (template.HTML, error) {
+	var b strings.Builder
+	if err := secureTemplate.Execute(&b, e); err != nil {
+		return "", err
+	}
+	return template.HTML(b.String()), nil
+}
+
+// ServeHTTP writes a page with the form returned by HTML.
+func (e *SecureRequired) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	form, err := e.HTML()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<body>\n%s\n</body>\n</html>\n", form)
+}
+
+// secureResponse is the body of a charge that requires 3-D Secure:
+//
+//	{"success": 0, "secure": {"formHTML": "<form action=\"...\" method=\"POST\">...</form>"}}
+type secureResponse struct {
+	Secure *struct {
+		FormHTML string `json:"formHTML"`
+	} `json:"secure"`
+}
+
+var (
+	formPattern  = regexp.MustCompile(`(?is)<form\b(?:[^>"']|"[^"]*"|'[^']*')*>`)
+	inputPattern = regexp.MustCompile(`(?is)<input\b(?:[^>"']|"[^"]*"|'[^']*')*>`)
+	// attributePattern matches the attributes of a tag in turn, so that an attribute such as
+	// data-name or one within a quoted value is not taken for another.
+	attributePattern = regexp.MustCompile(`\s([^\s"'>/=]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// tagAttributes returns the unescaped attributes of tag, by lower-case name.
+func tagAttributes(tag string) map[string]string {
+	attributes := make(map[string]string)
+	for _, m := range attributePattern.FindAllStringSubmatch(tag, -1) {
+		attributes[strings.ToLower(m[1])] = html.UnescapeString(m[2] + m[3] + m[4])
+	}
+	return attributes
+}
+
+// decodeSecure returns a *SecureRequired if the response requires 3-D Secure.
+func decodeSecure(body []byte) error {
+	var resp secureResponse
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Secure == nil || resp.Secure.FormHTML == "" {
+		return nil
+	}
+
+	formHTML := resp.Secure.FormHTML
+	secure := &SecureRequired{
+		Fields:   url.Values{},
+		FormHTML: formHTML,
+	}
+	if form := formPattern.FindString(formHTML); form != "" {
+		secure.RedirectURL = tagAttributes(form)["action"]
+	}
+	for _, input := range inputPattern.FindAllString(formHTML, -1) {
+		attributes := tagAttributes(input)
+		if name := attributes["name"]; name != "" {
+			secure.Fields.Add(name, attributes["value"])
+		}
+	}
+	return secure
+}
+
+// CompleteSecureCharge retries the charge of req after the user passed 3-D Secure, with the
+// brick_secure_token and brick_charge_id Brick sent back to the secure redirect URL.
+func (c *Client) CompleteSecureCharge(ctx context.Context, req ChargeRequest, secureToken, chargeID string) (*Charge, error) {
+	req.SecureToken = secureToken
+	req.ChargeID = chargeID
+	return c.CreateCharge(ctx, req)
+}
+
+// SecureHandler completes charges when users return from 3-D Secure to the secure redirect URL.
+type SecureHandler struct {
+	Client *Client
+
+	// Request returns the charge request that required 3-D Secure, saved by the application
+	// under its own parameter of SecureRedirectURL, e.g. r.FormValue("order").
+	Request func(r *http.Request) (ChargeRequest, error)
+	// Done writes the response to the user once the charge is completed, or has failed.
+	Done func(w http.ResponseWriter, r *http.Request, charge *Charge, err error)
+}
+
+func (h *SecureHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	secureToken := r.FormValue("brick_secure_token")
+	chargeID := r.FormValue("brick_charge_id")
+	if secureToken == "" || chargeID == "" {
+		http.Error(w, "brick_secure_token and brick_charge_id are required", http.StatusBadRequest)
+		return
+	}
+
+	req, err := h.Request(r)
+	if err != nil {
+		h.Done(w, r, nil, err)
+		return
+	}
+	charge, err := h.Client.CompleteSecureCharge(r.Context(), req, secureToken, chargeID)
+	h.Done(w, r, charge, err)
+}
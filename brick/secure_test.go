@@ -0,0 +1,97 @@
+package brick
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sanae10001/paymentwall-go"
+)
+
+const secureResponseBody = `{"success":0,"secure":{"formHTML":"<div><form data-action=\"x\" action=\"https://secure.example.com/3ds?a=1&amp;b=2\" method=\"POST\">` +
+	`<input type=\"hidden\" name=\"PaReq\" value=\"abc&quot;def\">` +
+	`<input type=\"hidden\" data-name=\"ignored\" name=\"MD\" value=\"it's > 1\">` +
+	`<input type=\"hidden\" name='TermUrl' value='https://api.paymentwall.com/term'></form></div>"}}`
+
+func TestClient_SecureRequired(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("secure_token") == "" {
+			fmt.Fprint(w, secureResponseBody)
+			return
+		}
+		if r.FormValue("secure_token") != "st_1" || r.FormValue("charge_id") != "ch_1" || r.FormValue("token") != "ot_1" {
+			t.Errorf("got form %v", r.Form)
+		}
+		fmt.Fprint(w, `{"object":"charge","id":"ch_1","captured":true}`)
+	})
+
+	req := ChargeRequest{
+		Token:             "ot_1",
+		Amount:            paymentwall.MustParseMoney("19.99", "USD"),
+		SecureRedirectURL: "https://shop.example.com/3ds?order=42",
+	}
+	_, err := c.CreateCharge(context.Background(), req)
+	var secure *SecureRequired
+	if !errors.As(err, &secure) {
+		t.Fatalf("got %v, want *SecureRequired", err)
+	}
+	if secure.RedirectURL != "https://secure.example.com/3ds?a=1&b=2" {
+		t.Errorf("got redirect URL %s", secure.RedirectURL)
+	}
+	want := url.Values{
+		"PaReq":   {`abc"def`},
+		"MD":      {"it's > 1"},
+		"TermUrl": {"https://api.paymentwall.com/term"},
+	}
+	if secure.Fields.Encode() != want.Encode() {
+		t.Errorf("got fields %v, want %v", secure.Fields, want)
+	}
+
+	form, err := secure.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`action="https://secure.example.com/3ds?a=1&amp;b=2"`,
+		`name="PaReq" value="abc&#34;def"`,
+		`.submit()`,
+	} {
+		if !strings.Contains(string(form), s) {
+			t.Errorf("form does not contain %s:\n%s", s, form)
+		}
+	}
+
+	h := &SecureHandler{
+		Client: c,
+		// The charge request is saved under the order ID of the secure redirect URL.
+		Request: func(r *http.Request) (ChargeRequest, error) {
+			if r.FormValue("order") != "42" {
+				return ChargeRequest{}, errors.New("unknown order")
+			}
+			return req, nil
+		},
+		Done: func(w http.ResponseWriter, r *http.Request, charge *Charge, err error) {
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusPaymentRequired)
+				return
+			}
+			fmt.Fprint(w, "paid "+charge.ID)
+		},
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/3ds?order=42&brick_secure_token=st_1&brick_charge_id=ch_1", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "paid ch_1" {
+		t.Errorf("got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/3ds", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package paymentwall
+
+var chargebackReasonDescriptions = map[ChargebackReason]string{
+	PingbackChargebackReason1:  "Chargeback",
+	PingbackChargebackReason2:  "Credit Card fraud",
+	PingbackChargebackReason3:  "Other fraud",
+	PingbackChargebackReason4:  "Bad data entry",
+	PingbackChargebackReason5:  "Fake / Proxy user",
+	PingbackChargebackReason6:  "Rejected by advertiser",
+	PingbackChargebackReason7:  "Duplicate conversions",
+	PingbackChargebackReason8:  "Goodwill credit taken back",
+	PingbackChargebackReason9:  "Canceled order",
+	PingbackChargebackReason10: "Partially reversed transaction",
+}
+
+// String returns the description of the reason, as in "Credit Card fraud".
+func (r ChargebackReason) String() string {
+	if d, ok := chargebackReasonDescriptions[r]; ok {
+		return d
+	}
+	if r == "" {
+		return "No reason"
+	}
+	return "Unknown reason " + string(r)
+}
+
+// IsKnown reports whether the reason is one documented by Paymentwall.
+func (r ChargebackReason) IsKnown() bool {
+	_, ok := chargebackReasonDescriptions[r]
+	return ok
+}
+
+// IsFraud reports whether the payment was fraudulent or made by a fake user.
+func (r ChargebackReason) IsFraud() bool {
+	return r == PingbackChargebackReason2 ||
+		r == PingbackChargebackReason3 ||
+		r == PingbackChargebackReason5
+}
+
+// RecommendsBan reports whether Paymentwall recommends banning the user.
+func (r ChargebackReason) RecommendsBan() bool {
+	return r == PingbackChargebackReason2 ||
+		r == PingbackChargebackReason3
+}
+
+// IsPartialReversal reports whether only part of the transaction was reversed,
+// in which case the goods may not have to be taken back entirely.
+func (r ChargebackReason) IsPartialReversal() bool {
+	return r == PingbackChargebackReason10
+}
+
+// IsRefund reports whether the order was canceled, e.g. refunded, rather than disputed.
+func (r ChargebackReason) IsRefund() bool {
+	return r == PingbackChargebackReason9
+}
@@ -29,20 +29,30 @@ const (
 const (
 	// https://docs.paymentwall.com/reference/signature-calculation
 	DefaultSignVersion = "3" // sha256
+	SignVersion1       = "1" // md5 over a fixed set of parameters, for legacy projects
 	SignVersion2       = "2" // md5
 	SignVersion3       = "3" // sha256
 )
 
+// ChargebackReason is the reason of a negative pingback.
+type ChargebackReason string
+
 const (
 	// https://docs.paymentwall.com/#chargeback-pingback
-	PingbackChargebackReason1  = "1"  // Chargeback
-	PingbackChargebackReason2  = "2"  // Credit Card fraud. Recommendation: Ban User
-	PingbackChargebackReason3  = "3"  // Other fraud. Recommendation: Ban User
-	PingbackChargebackReason4  = "4"  // Bad data entry
-	PingbackChargebackReason5  = "5"  // Fake / Proxy user
-	PingbackChargebackReason6  = "6"  // Rejected by advertiser
-	PingbackChargebackReason7  = "7"  // Duplicate conversions
-	PingbackChargebackReason8  = "8"  // Goodwill credit taken back
-	PingbackChargebackReason9  = "9"  // Canceled order, e.g. refund
-	PingbackChargebackReason10 = "10" // Partially reversed transaction
+	PingbackChargebackReason1  ChargebackReason = "1"  // Chargeback
+	PingbackChargebackReason2  ChargebackReason = "2"  // Credit Card fraud. Recommendation: Ban User
+	PingbackChargebackReason3  ChargebackReason = "3"  // Other fraud. Recommendation: Ban User
+	PingbackChargebackReason4  ChargebackReason = "4"  // Bad data entry
+	PingbackChargebackReason5  ChargebackReason = "5"  // Fake / Proxy user
+	PingbackChargebackReason6  ChargebackReason = "6"  // Rejected by advertiser
+	PingbackChargebackReason7  ChargebackReason = "7"  // Duplicate conversions
+	PingbackChargebackReason8  ChargebackReason = "8"  // Goodwill credit taken back
+	PingbackChargebackReason9  ChargebackReason = "9"  // Canceled order, e.g. refund
+	PingbackChargebackReason10 ChargebackReason = "10" // Partially reversed transaction
 )
+
+func isSignVersionSupported(signVersion string) bool {
+	return signVersion == SignVersion1 ||
+		signVersion == SignVersion2 ||
+		signVersion == SignVersion3
+}
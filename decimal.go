@@ -0,0 +1,62 @@
+package paymentwall
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact decimal number of Units / 10^Scale, e.g. {-1505, 1} for -150.5.
+type Decimal struct {
+	Units int64
+	Scale int
+}
+
+// ParseDecimal parses a decimal number such as "100" or "-150.5". Trailing zeros of the
+// fraction are dropped, so "1.50" has the scale 1.
+func ParseDecimal(s string) (Decimal, error) {
+	digits := strings.TrimPrefix(s, "-")
+	units, fraction := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		units, fraction = digits[:i], strings.TrimRight(digits[i+1:], "0")
+	}
+	if units == "" || !isDigits(units) || !isDigits(fraction) {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	n, err := strconv.ParseInt(units+fraction, 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("invalid decimal %q: %v", s, err)
+	}
+	if strings.HasPrefix(s, "-") {
+		n = -n
+	}
+	return Decimal{Units: n, Scale: len(fraction)}, nil
+}
+
+// Int64 returns the number if it has no fraction.
+func (d Decimal) Int64() (int64, bool) {
+	if d.Scale != 0 {
+		return 0, false
+	}
+	return d.Units, true
+}
+
+func (d Decimal) String() string {
+	sign, abs := "", uint64(d.Units)
+	if d.Units < 0 {
+		sign, abs = "-", uint64(-d.Units)
+	}
+	digits := strconv.FormatUint(abs, 10)
+	if d.Scale <= 0 {
+		return sign + digits
+	}
+	if len(digits) <= d.Scale {
+		digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-d.Scale] + "." + digits[len(digits)-d.Scale:]
+}
+
+func isDigits(s string) bool {
+	return strings.Trim(s, "0123456789") == ""
+}
@@ -0,0 +1,32 @@
+package paymentwall
+
+import "testing"
+
+func TestParseDecimal(t *testing.T) {
+	var tests = []struct {
+		s      string
+		d      Decimal
+		string string
+		err    bool
+	}{
+		{"100", Decimal{100, 0}, "100", false},
+		{"-150.5", Decimal{-1505, 1}, "-150.5", false},
+		{"1.50", Decimal{15, 1}, "1.5", false},
+		{"0.000001", Decimal{1, 6}, "0.000001", false},
+		{"0.1", Decimal{1, 1}, "0.1", false},
+		{"1e3", Decimal{}, "", true},
+		{"-", Decimal{}, "", true},
+		{".5", Decimal{}, "", true},
+		{"", Decimal{}, "", true},
+	}
+	for _, test := range tests {
+		d, err := ParseDecimal(test.s)
+		if (err != nil) != test.err {
+			t.Errorf("ParseDecimal(%q): got error %v", test.s, err)
+			continue
+		}
+		if err == nil && (d != test.d || d.String() != test.string) {
+			t.Errorf("ParseDecimal(%q) = %v (%s), want %v (%s)", test.s, d, d, test.d, test.string)
+		}
+	}
+}
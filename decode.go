@@ -0,0 +1,85 @@
+package paymentwall
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var ErrorInvalidDecodeTarget = errors.New("decode target must be a non-nil pointer to a struct")
+
+// Decode fills the fields of the struct pointed to by dst with the pingback parameters
+// named by their `pingback:"name"` tags. Fields without a tag and parameters missing from
+// the pingback are left untouched. Fields may be strings, booleans, numbers or implement
+// encoding.TextUnmarshaler.
+//
+//	var v struct {
+//		UID    string  `pingback:"uid"`
+//		Amount float64 `pingback:"currency"`
+//		Server int     `pingback:"server_id"`
+//	}
+//	err := pingback.Decode(&v)
+func (p *Pingback) Decode(dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrorInvalidDecodeTarget
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name := field.Tag.Get("pingback")
+		if name == "" || name == "-" || field.PkgPath != "" {
+			continue
+		}
+		value, ok := p.m[name]
+		if !ok {
+			continue
+		}
+		if err := decodeValue(rv.Field(i), value); err != nil {
+			return fmt.Errorf("decode %s into %s: %v", name, field.Name, err)
+		}
+	}
+	return nil
+}
+
+func decodeValue(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package paymentwall
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+var ErrorEmptyWidgetURL = errors.New("widget URL is empty, the widget cannot be signed")
+
+// EmbedOption configures the HTML rendered by the TemplateFuncs.
+type EmbedOption func(c *embedConfig)
+
+type embedConfig struct {
+	attributes map[string]string
+}
+
+// WithResponsive makes the widget iframe take the full width of its container,
+// instead of the fixed 750 pixels.
+func WithResponsive() EmbedOption {
+	return func(c *embedConfig) {
+		c.attributes["width"] = "100%"
+		c.attributes["style"] = "max-width: 100%; border: 0;"
+	}
+}
+
+// WithSize sets the width and height of the widget iframe, e.g. "640" and "100%".
+func WithSize(width, height string) EmbedOption {
+	return func(c *embedConfig) {
+		c.attributes["width"] = width
+		c.attributes["height"] = height
+	}
+}
+
+// WithIframeAttributes sets attributes of the widget iframe, as for Widget.GetHtmlCode.
+func WithIframeAttributes(attributes map[string]string) EmbedOption {
+	return func(c *embedConfig) {
+		for k, v := range attributes {
+			c.attributes[k] = v
+		}
+	}
+}
+
+// TemplateFuncs returns the functions rendering widgets in html/template:
+//
+//	{{paymentwallIframe .Widget}}              the widget in an iframe
+//	{{paymentwallLink .Widget "Buy now"}}      a link opening the widget in a new window
+//	{{paymentwallLightbox .Widget "Buy now"}}  a button opening the widget in a modal dialog
+//
+// The lightbox opens the dialog with an inline script. Under a Content-Security-Policy,
+// pass the nonce of the page: {{paymentwallLightbox .Widget "Buy now" .Nonce}}.
+func TemplateFuncs(opts ...EmbedOption) template.FuncMap {
+	c := &embedConfig{attributes: make(map[string]string)}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return template.FuncMap{
+		"paymentwallIframe": func(w *Widget) (template.HTML, error) {
+			return c.iframe(w)
+		},
+		"paymentwallLink": func(w *Widget, text string) (template.HTML, error) {
+			return c.link(w, text)
+		},
+		"paymentwallLightbox": func(w *Widget, text string, nonce ...string) (template.HTML, error) {
+			return c.lightbox(w, text, strings.Join(nonce, ""))
+		},
+	}
+}
+
+func widgetUrl(w *Widget) (string, error) {
+	u := w.GetUrl()
+	if u == "" {
+		return "", ErrorEmptyWidgetURL
+	}
+	return u, nil
+}
+
+func (c *embedConfig) iframe(w *Widget) (template.HTML, error) {
+	u, err := widgetUrl(w)
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(iframeHtml(u, c.attributes)), nil
+}
+
+func (c *embedConfig) link(w *Widget, text string) (template.HTML, error) {
+	u, err := widgetUrl(w)
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(fmt.Sprintf(`<a href="%s" target="_blank" rel="noopener">%s</a>`,
+		template.HTMLEscapeString(u), template.HTMLEscapeString(text))), nil
+}
+
+// The lightbox is a native dialog, so the browser handles focus, Escape and the backdrop.
+// The iframe is lazily loaded, when the dialog is first opened. The script finds the button
+// and the dialog next to it, so the page needs no element IDs.
+const lightboxHtml = `<button type="button">%[1]s</button>
+<dialog aria-label="%[1]s"><form method="dialog"><button type="submit" aria-label="Close">&times;</button></form>%[2]s</dialog>
+<script%[3]s>(function(s) {
+	var dialog = s.previousElementSibling;
+	dialog.previousElementSibling.addEventListener("click", function() { dialog.showModal(); });
+})(document.currentScript);</script>`
+
+func (c *embedConfig) lightbox(w *Widget, text, nonce string) (template.HTML, error) {
+	u, err := widgetUrl(w)
+	if err != nil {
+		return "", err
+	}
+
+	attributes := map[string]string{"loading": "lazy"}
+	for k, v := range c.attributes {
+		attributes[k] = v
+	}
+	if nonce != "" {
+		nonce = ` nonce="` + template.HTMLEscapeString(nonce) + `"`
+	}
+	return template.HTML(fmt.Sprintf(lightboxHtml,
+		template.HTMLEscapeString(text),
+		iframeHtml(u, attributes),
+		nonce)), nil
+}
@@ -0,0 +1,56 @@
+package paymentwall
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	w := newTestWidget(t, API_VC)
+	w.SetTimestamp(time.Unix(1500000000, 0))
+	src := `https://api.paymentwall.com/api/ps?email=&amp;key=app&amp;ps=all&amp;sign=` +
+		`f87ba103855eb65ad81d9ee416171e426cae2766017f16456eadca567990fc81&amp;sign_version=3&amp;` +
+		`timestamp=1500000000&amp;uid=user&amp;widget=p1`
+
+	tmpl := template.Must(template.New("page").Funcs(TemplateFuncs(WithResponsive())).Parse(
+		`{{paymentwallIframe .}}|{{paymentwallLink . "Buy <now>"}}|{{paymentwallLightbox . "Buy"}}`))
+	var b strings.Builder
+	if err := tmpl.Execute(&b, w); err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.SplitN(b.String(), "|", 3)
+
+	wantIframe := `<iframe src="` + src + `" frameborder="0" height="800" style="max-width: 100%; border: 0;" width="100%"></iframe>`
+	if parts[0] != wantIframe {
+		t.Errorf("got iframe\n%s\nwant\n%s", parts[0], wantIframe)
+	}
+	wantLink := `<a href="` + src + `" target="_blank" rel="noopener">Buy &lt;now&gt;</a>`
+	if parts[1] != wantLink {
+		t.Errorf("got link\n%s\nwant\n%s", parts[1], wantLink)
+	}
+	if strings.Contains(parts[2], "onclick") {
+		t.Errorf("lightbox has an inline event handler, which CSP blocks:\n%s", parts[2])
+	}
+	for _, s := range []string{`<iframe src="` + src + `"`, `loading="lazy"`, `>Buy</button>`, `<dialog aria-label="Buy">`} {
+		if !strings.Contains(parts[2], s) {
+			t.Errorf("lightbox does not contain %s:\n%s", s, parts[2])
+		}
+	}
+
+	nonced := template.Must(template.New("csp").Funcs(TemplateFuncs()).Parse(
+		`{{paymentwallLightbox . "Buy" "n0\"nce"}}`))
+	b.Reset()
+	if err := nonced.Execute(&b, w); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `<script nonce="n0&#34;nce">`) {
+		t.Errorf("lightbox script has no nonce:\n%s", b.String())
+	}
+
+	w.SetExtraParam("sign_version", "4")
+	if err := tmpl.Execute(&b, w); err == nil {
+		t.Error("expected error for a widget that cannot be signed")
+	}
+}
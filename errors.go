@@ -0,0 +1,76 @@
+package paymentwall
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors matching the ValidationError kinds, for use with errors.Is.
+var (
+	ErrorMissingParameter = errors.New("parameter is missing")
+	ErrorIPNotAllowed     = errors.New("IP address is not whitelisted")
+	ErrorWrongSignature   = errors.New("wrong signature")
+
+	ErrorUnknownSignVersion = errors.New("unknown sign version")
+)
+
+// ValidationKind is the reason a pingback failed validation.
+type ValidationKind int
+
+const (
+	ValidationMissingParameter ValidationKind = iota + 1
+	ValidationIPNotAllowed
+	ValidationWrongSignature
+	ValidationUnknownSignVersion
+)
+
+func (k ValidationKind) String() string {
+	switch k {
+	case ValidationMissingParameter:
+		return "missing parameter"
+	case ValidationIPNotAllowed:
+		return "IP not allowed"
+	case ValidationWrongSignature:
+		return "wrong signature"
+	case ValidationUnknownSignVersion:
+		return "unknown sign version"
+	}
+	return fmt.Sprintf("ValidationKind(%d)", int(k))
+}
+
+// ValidationError describes why a pingback is invalid. It matches the sentinel error
+// of its kind, e.g. errors.Is(err, ErrorWrongSignature).
+type ValidationError struct {
+	Kind ValidationKind
+	// The missing parameter for ValidationMissingParameter,
+	// or the sign version for ValidationUnknownSignVersion.
+	Param string
+}
+
+func (e *ValidationError) Error() string {
+	switch e.Kind {
+	case ValidationMissingParameter:
+		return fmt.Sprintf("Parameter %s is missing.", e.Param)
+	case ValidationIPNotAllowed:
+		return "IP address is not whitelisted"
+	case ValidationWrongSignature:
+		return "Wrong signature"
+	case ValidationUnknownSignVersion:
+		return fmt.Sprintf("Sign version %s is not supported.", e.Param)
+	}
+	return e.Kind.String()
+}
+
+func (e *ValidationError) Is(target error) bool {
+	switch target {
+	case ErrorMissingParameter:
+		return e.Kind == ValidationMissingParameter
+	case ErrorIPNotAllowed:
+		return e.Kind == ValidationIPNotAllowed
+	case ErrorWrongSignature:
+		return e.Kind == ValidationWrongSignature
+	case ErrorUnknownSignVersion:
+		return e.Kind == ValidationUnknownSignVersion
+	}
+	return false
+}
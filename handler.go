@@ -0,0 +1,141 @@
+package paymentwall
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// The response body Paymentwall expects once a pingback has been processed.
+// Any other response makes Paymentwall retry the pingback later.
+const pingbackResponseOK = "OK"
+
+// PingbackProcessor is implemented by the application to act on validated pingbacks.
+// A non-nil error makes the handler answer with an error, so Paymentwall will retry.
+type PingbackProcessor interface {
+	// Deliver is called when the goods should be delivered to the user.
+	Deliver(p *Pingback) error
+	// Cancel is called when the delivered goods should be taken back from the user.
+	Cancel(p *Pingback) error
+	// Review is called when the payment is under risk review. Do not deliver yet.
+	Review(p *Pingback) error
+	// SubscriptionEnded is called when a subscription is cancelled, expired or stopped
+	// because of failed payments.
+	SubscriptionEnded(p *Pingback) error
+}
+
+type PingbackHandlerConfig struct {
+	SecretKey string
+	ApiType   ApiType
+
+	// IPAllowlist holds the networks pingbacks are permitted to come from.
+	// Paymentwall's pingback subnets are used when it is nil.
+	IPAllowlist *IPAllowlist
+	// TrustedProxies holds the networks of the proxies in front of the handler, whose
+	// forwarding headers are used to find the pingback source IP. See ClientIP.
+	TrustedProxies *IPAllowlist
+	// SkipIPCheck disables the check of the pingback source IP.
+	SkipIPCheck bool
+
+	// ReferenceStore, when set, claims the pingbacks before they are processed. A pingback
+	// that has already been processed is acknowledged without calling the processor again,
+	// one that is still being processed is answered with 409 Conflict.
+	ReferenceStore ReferenceStore
+}
+
+// NewPingbackHandler returns a http.Handler that validates pingbacks and passes them
+// to processor.
+func NewPingbackHandler(config PingbackHandlerConfig, processor PingbackProcessor) *PingbackHandler {
+	return &PingbackHandler{
+		config:    config,
+		processor: processor,
+	}
+}
+
+type PingbackHandler struct {
+	config    PingbackHandlerConfig
+	processor PingbackProcessor
+}
+
+func (h *PingbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p := NewPingback(r.Form, ClientIP(r, h.config.TrustedProxies), h.config.ApiType, h.config.SecretKey)
+	p.SetIPAllowlist(h.config.IPAllowlist)
+	if err := p.Check(h.config.SkipIPCheck); err != nil {
+		http.Error(w, err.Error(), validationStatus(err))
+		return
+	}
+
+	if err := h.processOnce(p); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrorPingbackInProgress) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, pingbackResponseOK)
+}
+
+// processOnce processes p unless the ReferenceStore has already completed it. A pingback
+// still processed by another request fails with ErrorPingbackInProgress, so that Paymentwall
+// retries it in case that processing fails. The claim is released when the processor fails.
+func (h *PingbackHandler) processOnce(p *Pingback) error {
+	store := h.config.ReferenceStore
+	if store == nil {
+		return h.process(p)
+	}
+
+	ref, type_ := p.GetReferenceID(), p.GetType()
+	state, err := store.Claim(ref, type_)
+	if err != nil {
+		return err
+	}
+	switch state {
+	case ClaimCompleted:
+		return nil
+	case ClaimInProgress:
+		return ErrorPingbackInProgress
+	}
+
+	if err := h.process(p); err != nil {
+		if releaseErr := store.Release(ref, type_); releaseErr != nil {
+			return fmt.Errorf("%v; release reference %s: %v", err, ref, releaseErr)
+		}
+		return err
+	}
+	// The pingback has been processed, it is acknowledged even if the claim cannot be
+	// completed. It then stays in progress, which only delays later duplicates.
+	store.Complete(ref, type_)
+	return nil
+}
+
+func (h *PingbackHandler) process(p *Pingback) error {
+	switch {
+	case p.IsDeliverable():
+		return h.processor.Deliver(p)
+	case p.IsCancelable():
+		return h.processor.Cancel(p)
+	case p.IsUnderReview():
+		return h.processor.Review(p)
+	case p.IsSubscriptionEnded():
+		return h.processor.SubscriptionEnded(p)
+	}
+	// Nothing to do for the other types, acknowledge them so they are not resent.
+	return nil
+}
+
+func validationStatus(err error) int {
+	var verr *ValidationError
+	if errors.As(err, &verr) && verr.Kind != ValidationMissingParameter {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,224 @@
+package paymentwall
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+)
+
+type recordingProcessor struct {
+	called []string
+	err    error
+}
+
+func (r *recordingProcessor) Deliver(p *Pingback) error {
+	r.called = append(r.called, "deliver")
+	return r.err
+}
+
+func (r *recordingProcessor) Cancel(p *Pingback) error {
+	r.called = append(r.called, "cancel")
+	return r.err
+}
+
+func (r *recordingProcessor) Review(p *Pingback) error {
+	r.called = append(r.called, "review")
+	return r.err
+}
+
+func (r *recordingProcessor) SubscriptionEnded(p *Pingback) error {
+	r.called = append(r.called, "ended")
+	return r.err
+}
+
+func signV2(values url.Values, secretKey string) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	baseString := ""
+	for _, k := range keys {
+		baseString += k + "=" + values.Get(k)
+	}
+	sum := md5.Sum([]byte(baseString + secretKey))
+	values.Set("sig", hex.EncodeToString(sum[:]))
+}
+
+func TestPingbackHandler(t *testing.T) {
+	var tests = []struct {
+		type_  PingbackType
+		sig    string
+		err    error
+		status int
+		called string
+	}{
+		{PingbackTypeRegular, "", nil, http.StatusOK, "deliver"},
+		{PingbackTypeNegative, "", nil, http.StatusOK, "cancel"},
+		{PingbackTypeRiskUnderReview, "", nil, http.StatusOK, "review"},
+		{PingbackTypeSubscriptionExpired, "", nil, http.StatusOK, "ended"},
+		{PingbackTypeRiskAuthorizationVoided, "", nil, http.StatusOK, ""},
+		{PingbackTypeRegular, "bad", nil, http.StatusForbidden, ""},
+		{PingbackTypeRegular, "", errors.New("db down"), http.StatusInternalServerError, "deliver"},
+	}
+
+	for _, test := range tests {
+		values := url.Values{
+			"uid":          {"user"},
+			"goodsid":      {"product"},
+			"type":         {string(test.type_)},
+			"ref":          {"r1"},
+			"sign_version": {SignVersion2},
+		}
+		signV2(values, "secret")
+		if test.sig != "" {
+			values.Set("sig", test.sig)
+		}
+
+		processor := &recordingProcessor{err: test.err}
+		h := NewPingbackHandler(PingbackHandlerConfig{SecretKey: "secret", ApiType: API_GOODS}, processor)
+		req := httptest.NewRequest("GET", "/pingback?"+values.Encode(), nil)
+		req.RemoteAddr = "216.127.71.10:4321"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("type %s: got status %d, want %d", test.type_, rec.Code, test.status)
+		}
+		if test.status == http.StatusOK && rec.Body.String() != "OK" {
+			t.Errorf("type %s: got body %q, want OK", test.type_, rec.Body.String())
+		}
+		var called string
+		if len(processor.called) > 0 {
+			called = processor.called[0]
+		}
+		if called != test.called {
+			t.Errorf("type %s: got callback %q, want %q", test.type_, called, test.called)
+		}
+	}
+}
+
+func TestPingbackHandler_ReferenceStore(t *testing.T) {
+	values := url.Values{
+		"uid":          {"user"},
+		"goodsid":      {"product"},
+		"type":         {string(PingbackTypeRegular)},
+		"ref":          {"r1"},
+		"sign_version": {SignVersion2},
+	}
+	signV2(values, "secret")
+
+	processor := &recordingProcessor{}
+	h := NewPingbackHandler(PingbackHandlerConfig{
+		SecretKey:      "secret",
+		ApiType:        API_GOODS,
+		SkipIPCheck:    true,
+		ReferenceStore: NewMemoryReferenceStore(),
+	}, processor)
+
+	serve := func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/pingback?"+values.Encode(), nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "OK" {
+			t.Errorf("got %d %q, want 200 OK", rec.Code, rec.Body.String())
+		}
+	}
+
+	serve()
+	serve()
+	if len(processor.called) != 1 {
+		t.Errorf("retried pingback was processed %d times, want once", len(processor.called))
+	}
+
+	// A pingback whose processing failed is processed again when retried.
+	values.Set("ref", "r2")
+	values.Del("sig")
+	signV2(values, "secret")
+	processor.err = errors.New("db down")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/pingback?"+values.Encode(), nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want 500", rec.Code)
+	}
+	processor.err = nil
+	serve()
+	if len(processor.called) != 3 {
+		t.Errorf("got callbacks %v, want the failed pingback to be processed again", processor.called)
+	}
+
+	// A chargeback shares the reference ID of the payment.
+	values.Set("type", string(PingbackTypeNegative))
+	values.Del("sig")
+	signV2(values, "secret")
+	serve()
+	if len(processor.called) != 4 || processor.called[3] != "cancel" {
+		t.Errorf("got callbacks %v, want the chargeback to be cancelled", processor.called)
+	}
+}
+
+// blockingProcessor delivers once release is closed, with err.
+type blockingProcessor struct {
+	recordingProcessor
+	started, release chan struct{}
+}
+
+func (b *blockingProcessor) Deliver(p *Pingback) error {
+	b.started <- struct{}{}
+	<-b.release
+	return b.recordingProcessor.Deliver(p)
+}
+
+func TestPingbackHandler_RetryInProgress(t *testing.T) {
+	values := url.Values{
+		"uid":          {"user"},
+		"goodsid":      {"product"},
+		"type":         {string(PingbackTypeRegular)},
+		"ref":          {"r1"},
+		"sign_version": {SignVersion2},
+	}
+	signV2(values, "secret")
+
+	processor := &blockingProcessor{
+		recordingProcessor: recordingProcessor{err: errors.New("db down")},
+		started:            make(chan struct{}),
+		release:            make(chan struct{}),
+	}
+	h := NewPingbackHandler(PingbackHandlerConfig{
+		SecretKey:      "secret",
+		ApiType:        API_GOODS,
+		SkipIPCheck:    true,
+		ReferenceStore: NewMemoryReferenceStore(),
+	}, processor)
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/pingback?"+values.Encode(), nil))
+		return rec
+	}
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- serve() }()
+	<-processor.started
+
+	// The retry arrives before the first delivery fails.
+	if rec := serve(); rec.Code != http.StatusConflict {
+		t.Errorf("retry during processing: got status %d, want 409", rec.Code)
+	}
+	close(processor.release)
+	if rec := <-first; rec.Code != http.StatusInternalServerError {
+		t.Errorf("failed delivery: got status %d, want 500", rec.Code)
+	}
+
+	processor.err = nil
+	go func() { <-processor.started }()
+	if rec := serve(); rec.Code != http.StatusOK {
+		t.Errorf("retry after the failure: got status %d, want 200", rec.Code)
+	}
+	if rec := serve(); rec.Code != http.StatusOK || len(processor.called) != 2 {
+		t.Errorf("got status %d and callbacks %v, want the pingback delivered once more", rec.Code, processor.called)
+	}
+}
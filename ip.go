@@ -0,0 +1,207 @@
+package paymentwall
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// The subnet from which Paymentwall sends pingbacks by default.
+// @see: https://docs.paymentwall.com/reference/pingback-new-ip-subnet
+var defaultIPAllowlistCIDRs = []string{
+	"216.127.71.0/24",
+}
+
+var defaultIPAllowlist = MustIPAllowlist(defaultIPAllowlistCIDRs...)
+
+// IPAllowlist is a set of IPv4 and IPv6 networks that pingbacks are permitted to come from.
+// It is safe for concurrent use and may be updated while in use.
+type IPAllowlist struct {
+	mu   sync.RWMutex
+	nets []*net.IPNet
+}
+
+// NewIPAllowlist returns an allowlist of the given CIDRs. A plain IP address is treated
+// as a network of that single address.
+func NewIPAllowlist(cidrs ...string) (*IPAllowlist, error) {
+	a := &IPAllowlist{}
+	if err := a.Set(cidrs...); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// MustIPAllowlist is like NewIPAllowlist but panics if a CIDR cannot be parsed.
+func MustIPAllowlist(cidrs ...string) *IPAllowlist {
+	a, err := NewIPAllowlist(cidrs...)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// DefaultIPAllowlist returns a new allowlist of the Paymentwall pingback subnets.
+func DefaultIPAllowlist() *IPAllowlist {
+	return MustIPAllowlist(defaultIPAllowlistCIDRs...)
+}
+
+// Set replaces the networks of the allowlist. The allowlist is left unchanged on error.
+func (a *IPAllowlist) Set(cidrs ...string) error {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.nets = nets
+	a.mu.Unlock()
+	return nil
+}
+
+// Add appends networks to the allowlist. The allowlist is left unchanged on error.
+func (a *IPAllowlist) Add(cidrs ...string) error {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.nets = append(a.nets, nets...)
+	a.mu.Unlock()
+	return nil
+}
+
+// Contains reports whether ip is within one of the networks of the allowlist.
+func (a *IPAllowlist) Contains(ip string) bool {
+	reqIP := net.ParseIP(ip)
+	if reqIP == nil {
+		return false
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, n := range a.nets {
+		if n.Contains(reqIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// CIDRs returns the networks of the allowlist in CIDR notation.
+func (a *IPAllowlist) CIDRs() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	cidrs := make([]string, 0, len(a.nets))
+	for _, n := range a.nets {
+		cidrs = append(cidrs, n.String())
+	}
+	return cidrs
+}
+
+// MarshalText implements encoding.TextMarshaler. The networks are separated by commas.
+func (a *IPAllowlist) MarshalText() ([]byte, error) {
+	return []byte(strings.Join(a.CIDRs(), ",")), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, so an allowlist can be loaded
+// from configuration files and environment variables. The networks may be separated
+// by commas or whitespace.
+func (a *IPAllowlist) UnmarshalText(text []byte) error {
+	fields := strings.FieldsFunc(string(text), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	return a.Set(fields...)
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// ClientIP returns the address of the client that sent r, to be passed to NewPingback.
+// When the request comes through one of trustedProxies, the proxy chain in the Forwarded,
+// X-Forwarded-For or X-Real-IP header (in that order of preference) is walked from the
+// nearest hop until an address that is not a trusted proxy is found. The headers are ignored
+// when the direct peer is not trusted, so clients cannot spoof them. With nil trustedProxies,
+// the address of the direct peer is returned.
+func ClientIP(r *http.Request, trustedProxies *IPAllowlist) string {
+	ip := stripPort(r.RemoteAddr)
+	if trustedProxies == nil || !trustedProxies.Contains(ip) {
+		return ip
+	}
+
+	hops := forwardedFor(r.Header)
+	if len(hops) == 0 {
+		hops = splitHeader(r.Header, "X-Forwarded-For")
+	}
+	if len(hops) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+			hops = []string{realIP}
+		}
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip = stripPort(hops[i])
+		if !trustedProxies.Contains(ip) {
+			return ip
+		}
+	}
+	// Every hop is a trusted proxy, the first one is the closest we get to the client.
+	return ip
+}
+
+// forwardedFor returns the "for" parameters of the RFC 7239 Forwarded header.
+func forwardedFor(h http.Header) []string {
+	var hops []string
+	for _, element := range splitHeader(h, "Forwarded") {
+		for _, pair := range strings.Split(element, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+				hops = append(hops, strings.Trim(kv[1], `"`))
+			}
+		}
+	}
+	return hops
+}
+
+// splitHeader returns the comma separated values of all the header lines with the given name.
+func splitHeader(h http.Header, name string) []string {
+	var values []string
+	for _, line := range h[http.CanonicalHeaderKey(name)] {
+		for _, v := range strings.Split(line, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// stripPort removes the port and IPv6 brackets from addr, as in "[2001:db8::1]:4711" or "192.0.2.1:80".
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
@@ -0,0 +1,86 @@
+package paymentwall
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPAllowlist(t *testing.T) {
+	a, err := NewIPAllowlist("10.0.0.0/8", "2001:db8::/32", "192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		ip       string
+		contains bool
+	}{
+		{"10.1.2.3", true},
+		{"11.0.0.1", false},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+		{"::ffff:10.0.0.1", true},
+		{"216.127.71.1", false},
+		{"not an ip", false},
+	}
+	for _, test := range tests {
+		if a.Contains(test.ip) != test.contains {
+			t.Errorf("Contains(%q) = %v, want %v", test.ip, !test.contains, test.contains)
+		}
+	}
+
+	if err := a.UnmarshalText([]byte("216.127.71.0/24, 1.2.3.0/24")); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Contains("216.127.71.1") || a.Contains("10.1.2.3") {
+		t.Errorf("allowlist was not replaced, got %v", a.CIDRs())
+	}
+	if err := a.Add("bad/cidr"); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+
+	p := &Pingback{ip: "10.1.2.3"}
+	p.SetIPAllowlist(MustIPAllowlist("10.0.0.0/8"))
+	if !p.IsIPValid() {
+		t.Error("pingback should use its own allowlist")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := MustIPAllowlist("10.0.0.0/8", "2001:db8::/32")
+
+	var tests = []struct {
+		remoteAddr string
+		headers    map[string]string
+		trusted    *IPAllowlist
+		ip         string
+	}{
+		{"216.127.71.1:1234", nil, trusted, "216.127.71.1"},
+		{"216.127.71.1:1234", nil, nil, "216.127.71.1"},
+		// Headers of untrusted peers are ignored.
+		{"1.2.3.4:1234", map[string]string{"X-Forwarded-For": "216.127.71.1"}, trusted, "1.2.3.4"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "216.127.71.1"}, nil, "10.0.0.1"},
+		// The nearest untrusted hop wins over a spoofed leftmost entry.
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "6.6.6.6, 216.127.71.1, 10.0.0.2"}, trusted, "216.127.71.1"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, trusted, "10.0.0.3"},
+		{"10.0.0.1:1234", map[string]string{
+			"Forwarded":       `for=6.6.6.6, for="[2001:db9::1]:4711";proto=https, for=10.0.0.2`,
+			"X-Forwarded-For": "7.7.7.7",
+		}, trusted, "2001:db9::1"},
+		{"[2001:db8::1]:1234", map[string]string{"X-Real-IP": "216.127.71.2"}, trusted, "216.127.71.2"},
+		{"10.0.0.1:1234", nil, trusted, "10.0.0.1"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+		if ip := ClientIP(r, test.trusted); ip != test.ip {
+			t.Errorf("ClientIP(%s, %v) = %s, want %s", test.remoteAddr, test.headers, ip, test.ip)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package paymentwall
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	ErrorCurrencyMismatch = errors.New("currencies do not match")
+	ErrorMoneyOverflow    = errors.New("amount overflows int64")
+)
+
+// Money is an exact amount of an ISO 4217 currency, counted in its minor units,
+// e.g. cents for USD, yen for JPY and fils for KWD.
+type Money struct {
+	Amount   int64 // In minor units
+	Currency string
+}
+
+// NewMoney returns the amount of minor units of currency, e.g. NewMoney(1999, "USD") for 19.99 USD.
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney parses a decimal amount such as "19.99" of currency. It fails for unknown
+// currencies and for amounts with more decimals than the currency has.
+func ParseMoney(amount, currency string) (Money, error) {
+	exponent, ok := currencyExponents[currency]
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrorInvalidCurrency, currency)
+	}
+	return parseMoney(amount, currency, exponent)
+}
+
+// parseMoney parses amount into minor units of exponent decimals.
+func parseMoney(amount, currency string, exponent int) (Money, error) {
+	d, err := ParseDecimal(amount)
+	if err != nil || d.Scale > exponent {
+		return Money{}, fmt.Errorf("invalid %s amount %q", currency, amount)
+	}
+	units := d.Units
+	for i := d.Scale; i < exponent; i++ {
+		if units > math.MaxInt64/10 || units < math.MinInt64/10 {
+			return Money{}, fmt.Errorf("invalid %s amount %q: %w", currency, amount, ErrorMoneyOverflow)
+		}
+		units *= 10
+	}
+	return Money{Amount: units, Currency: currency}, nil
+}
+
+// MustParseMoney is like ParseMoney but panics if the amount cannot be parsed.
+func MustParseMoney(amount, currency string) Money {
+	m, err := ParseMoney(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Exponent returns the number of decimals of the currency, 2 for unknown currencies.
+func (m Money) Exponent() int {
+	if exponent, ok := currencyExponents[m.Currency]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// Decimal returns the amount as a decimal number with the decimals of the currency,
+// e.g. "19.99" for USD and "1999" for JPY.
+func (m Money) Decimal() string {
+	return Decimal{Units: m.Amount, Scale: m.Exponent()}.String()
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Mul returns the amount multiplied by n, e.g. the price of n items.
+func (m Money) Mul(n int64) (Money, error) {
+	product := m.Amount * n
+	// The division misses -1 * MinInt64, which overflows back to MinInt64.
+	if m.Amount != 0 && (product/m.Amount != n || (m.Amount == -1 && n == math.MinInt64)) {
+		return Money{}, fmt.Errorf("%w: %s * %d", ErrorMoneyOverflow, m, n)
+	}
+	return Money{Amount: product, Currency: m.Currency}, nil
+}
+
+// Add returns the sum of two amounts of the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrorCurrencyMismatch, m.Currency, o.Currency)
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrorMoneyOverflow, m, o)
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
@@ -0,0 +1,81 @@
+package paymentwall
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	var tests = []struct {
+		amount   string
+		currency string
+		minor    int64
+		decimal  string
+		err      bool
+	}{
+		{"19.99", "USD", 1999, "19.99", false},
+		{"19.9", "USD", 1990, "19.90", false},
+		{"19", "USD", 1900, "19.00", false},
+		{"0.05", "USD", 5, "0.05", false},
+		{"-1.50", "EUR", -150, "-1.50", false},
+		{"1999", "JPY", 1999, "1999", false},
+		{"1999.00", "JPY", 1999, "1999", false},
+		{"1.5", "JPY", 0, "", true},
+		{"1.234", "KWD", 1234, "1.234", false},
+		{"1.2345", "KWD", 0, "", true},
+		{"19.999", "USD", 0, "", true},
+		{"1e3", "USD", 0, "", true},
+		{"", "USD", 0, "", true},
+		{".5", "USD", 0, "", true},
+		{"1", "XYZ", 0, "", true},
+	}
+	for _, test := range tests {
+		m, err := ParseMoney(test.amount, test.currency)
+		if (err != nil) != test.err {
+			t.Errorf("ParseMoney(%q, %s): got error %v", test.amount, test.currency, err)
+			continue
+		}
+		if err == nil && (m.Amount != test.minor || m.Decimal() != test.decimal) {
+			t.Errorf("ParseMoney(%q, %s) = %d (%s), want %d (%s)",
+				test.amount, test.currency, m.Amount, m.Decimal(), test.minor, test.decimal)
+		}
+	}
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	price := MustParseMoney("19.99", "USD")
+	if total, err := price.Mul(3); err != nil || total.Decimal() != "59.97" {
+		t.Errorf("19.99 * 3 = %s, %v, want 59.97", total.Decimal(), err)
+	}
+	sum, err := price.Add(NewMoney(1, "USD"))
+	if err != nil || sum.String() != "20.00 USD" {
+		t.Errorf("got %v, %v", sum, err)
+	}
+	if _, err := price.Add(NewMoney(1, "EUR")); !errors.Is(err, ErrorCurrencyMismatch) {
+		t.Errorf("got %v, want %v", err, ErrorCurrencyMismatch)
+	}
+
+	max := NewMoney(math.MaxInt64, "USD")
+	if _, err := max.Add(NewMoney(1, "USD")); !errors.Is(err, ErrorMoneyOverflow) {
+		t.Errorf("max + 1: got %v, want %v", err, ErrorMoneyOverflow)
+	}
+	if _, err := NewMoney(math.MinInt64, "USD").Add(NewMoney(-1, "USD")); !errors.Is(err, ErrorMoneyOverflow) {
+		t.Errorf("min - 1: got %v, want %v", err, ErrorMoneyOverflow)
+	}
+	if _, err := max.Mul(2); !errors.Is(err, ErrorMoneyOverflow) {
+		t.Errorf("max * 2: got %v, want %v", err, ErrorMoneyOverflow)
+	}
+	if _, err := NewMoney(-1, "USD").Mul(math.MinInt64); !errors.Is(err, ErrorMoneyOverflow) {
+		t.Errorf("-1 * min: got %v, want %v", err, ErrorMoneyOverflow)
+	}
+	if m, err := max.Mul(1); err != nil || m != max {
+		t.Errorf("max * 1 = %v, %v", m, err)
+	}
+	if _, err := ParseMoney("92233720368547758.08", "USD"); err == nil {
+		t.Error("expected error for an amount overflowing int64")
+	}
+	if _, err := ParseMoney("922337203685477580.7", "USD"); !errors.Is(err, ErrorMoneyOverflow) {
+		t.Errorf("got %v, want %v", err, ErrorMoneyOverflow)
+	}
+}
@@ -0,0 +1,157 @@
+// Package paymentwalltest provides utilities for testing code that processes Paymentwall pingbacks.
+package paymentwalltest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sanae10001/paymentwall-go"
+)
+
+// PaymentwallIP is an address within the Paymentwall pingback subnet.
+const PaymentwallIP = "216.127.71.1"
+
+// LoopbackAllowlist returns an allowlist of the loopback addresses, from which the pingbacks
+// sent by Post to a local httptest.Server come.
+func LoopbackAllowlist() *paymentwall.IPAllowlist {
+	return paymentwall.MustIPAllowlist("127.0.0.0/8", "::1")
+}
+
+var refCounter uint64
+
+func nextRef() string {
+	return fmt.Sprintf("test_ref_%d", atomic.AddUint64(&refCounter, 1))
+}
+
+// NewPingback returns a test pingback of the given type, with the parameters required for
+// apiType and a unique reference ID. It is signed with DefaultSignVersion.
+func NewPingback(apiType paymentwall.ApiType, secretKey string, t paymentwall.PingbackType) *Pingback {
+	p := &Pingback{
+		ApiType:     apiType,
+		SecretKey:   secretKey,
+		SignVersion: paymentwall.DefaultSignVersion,
+		Values: url.Values{
+			"uid":     {"test_user"},
+			"type":    {string(t)},
+			"ref":     {nextRef()},
+			"is_test": {"1"},
+		},
+	}
+	switch apiType {
+	case paymentwall.API_VC:
+		p.Values.Set("currency", "100")
+	case paymentwall.API_GOODS:
+		p.Values.Set("goodsid", "test_product")
+	case paymentwall.API_CART:
+		p.Values.Set("goodsid[0]", "test_product")
+	}
+	return p
+}
+
+// Chargeback returns a negative pingback with the given reason.
+func Chargeback(apiType paymentwall.ApiType, secretKey string, reason paymentwall.ChargebackReason) *Pingback {
+	p := NewPingback(apiType, secretKey, paymentwall.PingbackTypeNegative).Set("reason", string(reason))
+	if apiType == paymentwall.API_VC {
+		p.Set("currency", "-100")
+	}
+	return p
+}
+
+// RiskReview returns a pingback of a payment under risk review.
+func RiskReview(apiType paymentwall.ApiType, secretKey string) *Pingback {
+	return NewPingback(apiType, secretKey, paymentwall.PingbackTypeRiskUnderReview)
+}
+
+// SubscriptionRenewal returns the pingback of a monthly renewal of the subscription whose
+// first payment had the reference ID initialRef.
+func SubscriptionRenewal(secretKey, productID, initialRef string) *Pingback {
+	return NewPingback(paymentwall.API_GOODS, secretKey, paymentwall.PingbackTypeRegular).
+		Set("goodsid", productID).
+		Set("slength", "1").
+		Set("speriod", string(paymentwall.PeriodTypeMonth)).
+		Set("initial_ref", initialRef)
+}
+
+// Pingback builds a signed pingback.
+type Pingback struct {
+	ApiType     paymentwall.ApiType
+	SecretKey   string
+	SignVersion string
+
+	// Values are the parameters of the pingback, without sig and sign_version.
+	Values url.Values
+}
+
+// Set sets a parameter of the pingback and returns p.
+func (p *Pingback) Set(key, value string) *Pingback {
+	p.Values.Set(key, value)
+	return p
+}
+
+// WithSignVersion sets the sign version and returns p.
+func (p *Pingback) WithSignVersion(signVersion string) *Pingback {
+	p.SignVersion = signVersion
+	return p
+}
+
+// Encode returns the signed parameters of the pingback. Like Paymentwall, version 1
+// VC pingbacks are sent without sign_version.
+func (p *Pingback) Encode() (url.Values, error) {
+	values := make(url.Values, len(p.Values)+2)
+	for k, v := range p.Values {
+		values[k] = append([]string(nil), v...)
+	}
+	if p.SignVersion != paymentwall.SignVersion1 || p.ApiType != paymentwall.API_VC {
+		values.Set("sign_version", p.SignVersion)
+	}
+
+	signer := paymentwall.NewPingbackSigner(p.ApiType, p.SecretKey, p.SignVersion)
+	sig, err := signer.Sign(values)
+	if err != nil {
+		return nil, err
+	}
+	values.Set("sig", sig)
+	return values, nil
+}
+
+// Pingback returns the pingback as parsed by paymentwall.NewPingback, as if sent from PaymentwallIP.
+func (p *Pingback) Pingback() (*paymentwall.Pingback, error) {
+	values, err := p.Encode()
+	if err != nil {
+		return nil, err
+	}
+	return paymentwall.NewPingback(values, PaymentwallIP, p.ApiType, p.SecretKey), nil
+}
+
+// Request returns a GET request of the pingback to target, as sent by Paymentwall from
+// PaymentwallIP, for use with httptest.ResponseRecorder.
+func (p *Pingback) Request(target string) (*http.Request, error) {
+	values, err := p.Encode()
+	if err != nil {
+		return nil, err
+	}
+	sep := "?"
+	if strings.Contains(target, "?") {
+		sep = "&"
+	}
+	r := httptest.NewRequest(http.MethodGet, target+sep+values.Encode(), nil)
+	r.RemoteAddr = PaymentwallIP + ":443"
+	return r, nil
+}
+
+// Post sends the pingback as a form to url, e.g. of a httptest.Server, with client or
+// http.DefaultClient if nil.
+func (p *Pingback) Post(client *http.Client, url string) (*http.Response, error) {
+	values, err := p.Encode()
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.PostForm(url, values)
+}
@@ -0,0 +1,75 @@
+package paymentwalltest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sanae10001/paymentwall-go"
+)
+
+func TestPingback(t *testing.T) {
+	apiTypes := []paymentwall.ApiType{paymentwall.API_VC, paymentwall.API_GOODS, paymentwall.API_CART}
+	types := []paymentwall.PingbackType{
+		paymentwall.PingbackTypeRegular,
+		paymentwall.PingbackTypeNegative,
+		paymentwall.PingbackTypeRiskUnderReview,
+		paymentwall.PingbackTypeSubscriptionExpired,
+	}
+	versions := []string{paymentwall.SignVersion1, paymentwall.SignVersion2, paymentwall.SignVersion3}
+
+	for _, apiType := range apiTypes {
+		for _, type_ := range types {
+			for _, version := range versions {
+				p, err := NewPingback(apiType, "secret", type_).WithSignVersion(version).Pingback()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := p.Check(false); err != nil {
+					t.Errorf("api %d, type %s, version %s: %v", apiType, type_, version, err)
+				}
+			}
+		}
+	}
+}
+
+type processor struct {
+	delivered, cancelled, reviewed int
+}
+
+func (p *processor) Deliver(*paymentwall.Pingback) error           { p.delivered++; return nil }
+func (p *processor) Cancel(*paymentwall.Pingback) error            { p.cancelled++; return nil }
+func (p *processor) Review(*paymentwall.Pingback) error            { p.reviewed++; return nil }
+func (p *processor) SubscriptionEnded(*paymentwall.Pingback) error { return nil }
+
+func TestPingback_Post(t *testing.T) {
+	proc := &processor{}
+	server := httptest.NewServer(paymentwall.NewPingbackHandler(paymentwall.PingbackHandlerConfig{
+		SecretKey:   "secret",
+		ApiType:     paymentwall.API_GOODS,
+		IPAllowlist: LoopbackAllowlist(),
+	}, proc))
+	defer server.Close()
+
+	pingbacks := []*Pingback{
+		NewPingback(paymentwall.API_GOODS, "secret", paymentwall.PingbackTypeRegular),
+		SubscriptionRenewal("secret", "test_product", "test_ref_0"),
+		Chargeback(paymentwall.API_GOODS, "secret", paymentwall.PingbackChargebackReason2),
+		RiskReview(paymentwall.API_GOODS, "secret"),
+	}
+	for _, p := range pingbacks {
+		resp, err := p.Post(nil, server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != "OK" {
+			t.Errorf("got %d %q, want 200 OK", resp.StatusCode, body)
+		}
+	}
+	if proc.delivered != 2 || proc.cancelled != 1 || proc.reviewed != 1 {
+		t.Errorf("got %+v", proc)
+	}
+}
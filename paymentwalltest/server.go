@@ -0,0 +1,195 @@
+package paymentwalltest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/sanae10001/paymentwall-go"
+)
+
+// Server is a local stand-in for the Paymentwall widget endpoints. It verifies the signature
+// of widget calls, renders a minimal checkout page and, when a purchase is completed, sends
+// a signed pingback to PingbackURL.
+type Server struct {
+	*httptest.Server
+
+	SecretKey   string
+	PingbackURL string
+
+	// Client sends the pingbacks, http.DefaultClient if nil.
+	Client *http.Client
+	// AllowUnsigned accepts widget calls without a signature, as sent by widgets with
+	// Config.SkipSignature. They are rejected by default.
+	AllowUnsigned bool
+}
+
+// NewServer starts a Server. Pass its BaseURL to Widget.SetBaseURL.
+func NewServer(secretKey, pingbackURL string) *Server {
+	s := &Server{
+		SecretKey:   secretKey,
+		PingbackURL: pingbackURL,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/"+paymentwall.VC_CONTROLLER, s.checkout(paymentwall.API_VC))
+	mux.HandleFunc("/api/"+paymentwall.GOODS_CONTROLLER, s.checkout(paymentwall.API_GOODS))
+	mux.HandleFunc("/api/"+paymentwall.CART_CONTROLLER, s.checkout(paymentwall.API_CART))
+	mux.HandleFunc("/api/complete", s.complete)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// BaseURL returns the URL to pass to Widget.SetBaseURL.
+func (s *Server) BaseURL() string {
+	return s.URL + "/api"
+}
+
+// Complete completes the purchase of the widget at widgetUrl, as if the user paid on the
+// checkout page, and returns the response of the pingback.
+func (s *Server) Complete(widgetUrl string) (*http.Response, error) {
+	u, err := url.Parse(widgetUrl)
+	if err != nil {
+		return nil, err
+	}
+	apiType, err := controllerApiType(u.Path)
+	if err != nil {
+		return nil, err
+	}
+	params := u.Query()
+	if err := s.verify(params); err != nil {
+		return nil, err
+	}
+	return s.pingback(apiType, params).Post(s.Client, s.PingbackURL)
+}
+
+func controllerApiType(path string) (paymentwall.ApiType, error) {
+	switch path[strings.LastIndex(path, "/")+1:] {
+	case paymentwall.VC_CONTROLLER:
+		return paymentwall.API_VC, nil
+	case paymentwall.GOODS_CONTROLLER:
+		return paymentwall.API_GOODS, nil
+	case paymentwall.CART_CONTROLLER:
+		return paymentwall.API_CART, nil
+	}
+	return 0, fmt.Errorf("unknown widget controller %s", path)
+}
+
+// ErrorMissingSignature is returned for unsigned widget calls, unless AllowUnsigned is set.
+var ErrorMissingSignature = errors.New("widget call is not signed")
+
+// verify checks the signature of widget parameters.
+func (s *Server) verify(params url.Values) error {
+	sign := params.Get("sign")
+	if sign == "" {
+		if s.AllowUnsigned {
+			return nil
+		}
+		return ErrorMissingSignature
+	}
+	signed := make(url.Values, len(params))
+	for k, v := range params {
+		if k != "sign" {
+			signed[k] = v
+		}
+	}
+	signVersion := params.Get("sign_version")
+	if signVersion == "" {
+		signVersion = paymentwall.SignVersion2
+	}
+	return paymentwall.Verify(signed, sign, s.SecretKey, signVersion)
+}
+
+// pingback returns the pingback of the purchase of the widget with params.
+func (s *Server) pingback(apiType paymentwall.ApiType, params url.Values) *Pingback {
+	p := NewPingback(apiType, s.SecretKey, paymentwall.PingbackTypeRegular).
+		Set("uid", params.Get("uid"))
+
+	switch apiType {
+	case paymentwall.API_GOODS:
+		p.Set("goodsid", params.Get("ag_external_id"))
+		if params.Get("ag_type") == string(paymentwall.ProductTypeSubscription) {
+			p.Set("slength", params.Get("ag_period_length"))
+			p.Set("speriod", params.Get("ag_period_type"))
+			if params.Get("ag_trial") == "1" {
+				p.Set("is_trial", "1")
+			}
+		}
+	case paymentwall.API_CART:
+		p.Values.Del("goodsid[0]")
+		for i := 0; ; i++ {
+			id := params.Get(fmt.Sprintf("external_ids[%d]", i))
+			if id == "" {
+				break
+			}
+			p.Set(fmt.Sprintf("goodsid[%d]", i), id)
+			if price := params.Get(fmt.Sprintf("prices[%d]", i)); price != "" {
+				p.Set(fmt.Sprintf("prices[%d]", i), price)
+			}
+			if currency := params.Get(fmt.Sprintf("currencies[%d]", i)); currency != "" {
+				p.Set(fmt.Sprintf("currencies[%d]", i), currency)
+			}
+		}
+	}
+	return p
+}
+
+var checkoutTemplate = template.Must(template.New("checkout").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Paymentwall test checkout</title></head>
+<body>
+<h1>Test checkout</h1>
+<dl>
+{{range $k, $v := .Params}}<dt>{{$k}}</dt><dd>{{index $v 0}}</dd>
+{{end}}</dl>
+<form method="post" action="complete">
+<input type="hidden" name="widget_url" value="{{.WidgetUrl}}">
+<button type="submit">Complete purchase</button>
+</form>
+</body>
+</html>
+`))
+
+func (s *Server) checkout(apiType paymentwall.ApiType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		if err := s.verify(params); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var b bytes.Buffer
+		err := checkoutTemplate.Execute(&b, struct {
+			Params    url.Values
+			WidgetUrl string
+		}{params, s.URL + r.URL.RequestURI()})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		b.WriteTo(w)
+	}
+}
+
+func (s *Server) complete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp, err := s.Complete(r.FormValue("widget_url"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		http.Error(w, "pingback failed: "+resp.Status, http.StatusBadGateway)
+		return
+	}
+	fmt.Fprintln(w, "Purchase completed")
+}
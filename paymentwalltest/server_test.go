@@ -0,0 +1,95 @@
+package paymentwalltest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sanae10001/paymentwall-go"
+)
+
+func TestServer(t *testing.T) {
+	proc := &processor{}
+	pingbackServer := httptest.NewServer(paymentwall.NewPingbackHandler(paymentwall.PingbackHandlerConfig{
+		SecretKey:   "secret",
+		ApiType:     paymentwall.API_GOODS,
+		IPAllowlist: LoopbackAllowlist(),
+	}, proc))
+	defer pingbackServer.Close()
+
+	server := NewServer("secret", pingbackServer.URL)
+	defer server.Close()
+
+	w, err := paymentwall.NewWidget(paymentwall.Config{
+		AppKey:     "app",
+		SecretKey:  "secret",
+		ApiType:    paymentwall.API_GOODS,
+		WidgetCode: "p1",
+	},
+		paymentwall.WithUID("user"),
+		paymentwall.WithBaseURL(server.BaseURL()),
+		paymentwall.WithProducts(*paymentwall.NewProduct("Gold", "gold", paymentwall.MustParseMoney("9.99", "USD"), paymentwall.ProductTypeFixed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	widgetUrl := w.GetUrl()
+	if !strings.HasPrefix(widgetUrl, server.URL) {
+		t.Fatalf("widget URL %s does not use the test server", widgetUrl)
+	}
+
+	resp, err := http.Get(widgetUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("checkout: got status %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(strings.Replace(widgetUrl, "uid=user", "uid=other", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("tampered checkout: got status %d, want 400", resp.StatusCode)
+	}
+
+	// A widget that lost its signature is rejected unless unsigned widgets are allowed.
+	u, err := url.Parse(widgetUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	query.Del("sign")
+	query.Del("sign_version")
+	u.RawQuery = query.Encode()
+	if _, err := server.Complete(u.String()); !errors.Is(err, ErrorMissingSignature) {
+		t.Errorf("unsigned widget: got %v, want %v", err, ErrorMissingSignature)
+	}
+	server.AllowUnsigned = true
+	resp, err = http.Get(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("allowed unsigned checkout: got status %d", resp.StatusCode)
+	}
+	server.AllowUnsigned = false
+
+	resp, err = http.PostForm(server.URL+"/api/complete", url.Values{"widget_url": {widgetUrl}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("complete: got status %d", resp.StatusCode)
+	}
+	if proc.delivered != 1 {
+		t.Errorf("got %d deliveries, want 1", proc.delivered)
+	}
+}
@@ -1,40 +1,34 @@
 package paymentwall
 
 import (
-	"bytes"
-	"crypto/md5"
-	"crypto/sha256"
-	"encoding/hex"
-	"errors"
 	"fmt"
-	"hash"
-	"net"
 	"net/url"
-	"sort"
-)
-
-// The whitelisted start and end range of which Paymentwall callbacks are permissible to come from.
-const (
-	ipWhitelistStart = "216.127.71.0"   // Start
-	ipWhitelistEnd   = "216.127.71.255" // End
+	"strconv"
 )
 
 // https://docs.paymentwall.com/reference/pingback-home
 func NewPingback(
 	values url.Values,
 	ip string, apiType ApiType, secretKey string) *Pingback {
+	signVersion := DefaultSignVersion
+	if apiType == API_VC {
+		signVersion = SignVersion1 // Legacy VC pingbacks are signed without sign_version.
+	}
 	p := Pingback{
 		m:           make(map[string]string, len(values)),
-		keys:        make([]string, 0, len(values)),
-		signVersion: DefaultSignVersion,
+		signVersion: signVersion,
 		IsTest:      false,
 		ip:          ip,
 		apiType:     apiType,
 		secretKey:   secretKey,
 		errors:      make([]error, 0, 2),
 	}
-	for k := range values {
-		v := values.Get(k)
+	for k, vs := range expandArrayParams(values) {
+		if len(vs) == 0 {
+			continue
+		}
+		// Like Paymentwall, which decodes pingbacks as PHP does, keep the last of repeated values.
+		v := vs[len(vs)-1]
 		if k == "sign_version" {
 			p.signVersion = v
 		} else if k == "is_test" && v == "1" {
@@ -46,29 +40,39 @@ func NewPingback(
 }
 
 type Pingback struct {
-	keys []string
-	m    map[string]string
+	m map[string]string
 
 	signVersion string
 	IsTest      bool
 
-	ip        string
-	apiType   ApiType
-	secretKey string
+	ip          string
+	ipAllowlist *IPAllowlist
+	apiType     ApiType
+	secretKey   string
 
 	errors []error
 }
 
+// CartItem is a product delivered by a Cart API pingback.
+type CartItem struct {
+	ProductID string
+	Amount    Money // Zero when the pingback carries no price, without currency when it carries none.
+}
+
 func (p *Pingback) set(key, value string) {
 	p.m[key] = value
-	p.keys = append(p.keys, key)
 }
 
-func (p *Pingback) appendToError(errMsg string) {
-	p.errors = append(p.errors, errors.New(errMsg))
+func (p *Pingback) appendToError(err *ValidationError) error {
+	p.errors = append(p.errors, err)
+	return err
 }
 
+// GetError returns the first validation error, or nil if there is none.
 func (p *Pingback) GetError() error {
+	if len(p.errors) == 0 {
+		return nil
+	}
 	return p.errors[0]
 }
 
@@ -77,78 +81,77 @@ func (p *Pingback) GetErrors() []error {
 }
 
 func (p *Pingback) Validate(skipIPCheck bool) bool {
-	var validated bool
-	if p.IsParametersValid() {
-		if p.IsIPValid() || skipIPCheck {
-			if p.IsSignatureValid() {
-				validated = true
-			} else {
-				p.appendToError("Wrong signature")
-			}
-		} else {
-			p.appendToError("IP address is not whitelisted")
-		}
+	return p.Check(skipIPCheck) == nil
+}
+
+// Check validates the pingback like Validate, but returns the reason of the failure
+// as a *ValidationError.
+func (p *Pingback) Check(skipIPCheck bool) error {
+	if err := p.checkParameters(); err != nil {
+		return err
+	}
+	if !skipIPCheck && !p.IsIPValid() {
+		return p.appendToError(&ValidationError{Kind: ValidationIPNotAllowed})
+	}
+	if !isSignVersionSupported(p.signVersion) {
+		return p.appendToError(&ValidationError{Kind: ValidationUnknownSignVersion, Param: p.signVersion})
+	}
+	if !p.IsSignatureValid() {
+		return p.appendToError(&ValidationError{Kind: ValidationWrongSignature})
 	}
-	return validated
+	return nil
 }
 
 func (p *Pingback) IsParametersValid() bool {
+	return p.checkParameters() == nil
+}
+
+func (p *Pingback) checkParameters() error {
 	var requiredParams []string
 	if p.apiType == API_VC {
-		requiredParams = []string{"uid", "type", "ref", "sig", "sign_version", "currency"}
+		requiredParams = []string{"uid", "type", "ref", "sig", "currency"}
 	} else if p.apiType == API_GOODS {
 		requiredParams = []string{"uid", "type", "ref", "sig", "sign_version", "goodsid"}
+	} else if p.apiType == API_CART {
+		requiredParams = []string{"uid", "type", "ref", "sig", "sign_version", "goodsid[0]"}
 	}
 
 	for _, k := range requiredParams {
 		if _, ok := p.m[k]; !ok {
-			p.appendToError(fmt.Sprintf("Parameter %s is missing.", k))
-			return false
+			return p.appendToError(&ValidationError{Kind: ValidationMissingParameter, Param: k})
 		}
 	}
-	return true
+	return nil
+}
+
+// SetIPAllowlist sets the networks from which the pingback is permitted to come.
+// Paymentwall's pingback subnets are used when it is not set.
+func (p *Pingback) SetIPAllowlist(a *IPAllowlist) {
+	p.ipAllowlist = a
 }
 
-// IsIPValid checks to ensure the IP from which the pingback was received is within the allowed range of whitelisted
-// IPs provided by Paymentwall.
+// IsIPValid checks to ensure the IP from which the pingback was received is within the allowlist,
+// by default the whitelisted IPs provided by Paymentwall.
 // @see: https://docs.paymentwall.com/reference/pingback-new-ip-subnet
 func (p *Pingback) IsIPValid() bool {
-	whitelistStart := net.ParseIP(ipWhitelistStart)
-	whitelistEnd := net.ParseIP(ipWhitelistEnd)
-	reqIP := net.ParseIP(p.ip)
-
-	// Ensure IP is IPv4 only.
-	if reqIP.To4() == nil {
-		return false
-	}
-
-	// Check if IP is within range of whitelisted IPs.
-	if bytes.Compare(reqIP, whitelistStart) >= 0 && bytes.Compare(reqIP, whitelistEnd) <= 0 {
-		return true
+	a := p.ipAllowlist
+	if a == nil {
+		a = defaultIPAllowlist
 	}
-
-	return false
+	return a.Contains(p.ip)
 }
 
 func (p *Pingback) IsSignatureValid() bool {
-	var h hash.Hash
-	if p.signVersion == SignVersion3 {
-		h = sha256.New()
-	} else {
-		h = md5.New()
-	}
+	signer := NewPingbackSigner(p.apiType, p.secretKey, p.signVersion)
+	return signer.Verify(p.values(), p.m["sig"]) == nil
+}
 
-	sort.Strings(p.keys)
-	baseString := ""
-	for _, k := range p.keys {
-		if k == "sig" {
-			continue
-		}
-		baseString += fmt.Sprintf(`%s=%s`, k, p.m[k])
+func (p *Pingback) values() url.Values {
+	values := make(url.Values, len(p.m))
+	for k, v := range p.m {
+		values.Set(k, v)
 	}
-	baseString += p.secretKey
-	h.Write([]byte(baseString))
-	return hex.EncodeToString(h.Sum(nil)) == p.m["sig"]
+	return values
 }
 
 func (p *Pingback) Get(key string) string {
@@ -172,6 +175,35 @@ func (p *Pingback) GetProductID() string {
 	return p.Get("goodsid")
 }
 
+// GetCartItems returns the products purchased through the Cart API, in the order
+// of their goodsid[N] parameters.
+func (p *Pingback) GetCartItems() ([]CartItem, error) {
+	items := make([]CartItem, 0, 1)
+	for i := 0; ; i++ {
+		id, ok := p.m[fmt.Sprintf("goodsid[%d]", i)]
+		if !ok {
+			break
+		}
+		item := CartItem{ProductID: id}
+		if amount := p.Get(fmt.Sprintf("prices[%d]", i)); amount != "" {
+			var m Money
+			var err error
+			if currency := p.Get(fmt.Sprintf("currencies[%d]", i)); currency != "" {
+				m, err = ParseMoney(amount, currency)
+			} else {
+				// Without a currency, the amount has the 2 decimals of Money.Exponent.
+				m, err = parseMoney(amount, "", 2)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid prices[%d]: %v", i, err)
+			}
+			item.Amount = m
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
 func (p *Pingback) GetProductPeriod() (length string, period string) {
 	return p.Get("slength"), p.Get("speriod")
 }
@@ -180,6 +212,77 @@ func (p *Pingback) GetReferenceID() string {
 	return p.Get("ref")
 }
 
+// VCAmount returns the amount of virtual currency to deliver or take back.
+// It is negative for negative pingbacks.
+func (p *Pingback) VCAmount() (Decimal, error) {
+	amount, err := ParseDecimal(p.Get("currency"))
+	if err != nil {
+		return Decimal{}, fmt.Errorf("invalid currency: %v", err)
+	}
+	return amount, nil
+}
+
+// ProductPeriod returns the period of a subscription product.
+func (p *Pingback) ProductPeriod() (uint, PeriodType, error) {
+	length, err := strconv.ParseUint(p.Get("slength"), 10, 32)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid slength: %v", err)
+	}
+	periodType := PeriodType(p.Get("speriod"))
+	switch periodType {
+	case PeriodTypeDay, PeriodTypeWeek, PeriodTypeMonth, PeriodTypeYear:
+	default:
+		return 0, "", fmt.Errorf("invalid speriod: %q", periodType)
+	}
+	return uint(length), periodType, nil
+}
+
+// Reason returns the reason of a negative pingback.
+func (p *Pingback) Reason() ChargebackReason {
+	return ChargebackReason(p.Get("reason"))
+}
+
+// ChargebackReason is the same as Reason.
+func (p *Pingback) ChargebackReason() ChargebackReason {
+	return p.Reason()
+}
+
+// SignVersion returns the version of the pingback signature.
+func (p *Pingback) SignVersion() string {
+	return p.signVersion
+}
+
+// The parameters defined by Paymentwall, as opposed to custom pingback parameters.
+var pingbackParams = map[string]bool{
+	"uid":          true,
+	"goodsid":      true,
+	"slength":      true,
+	"speriod":      true,
+	"type":         true,
+	"ref":          true,
+	"sig":          true,
+	"sign_version": true,
+	"is_test":      true,
+	"currency":     true,
+	"reason":       true,
+	"is_trial":     true,
+	"initial_ref":  true,
+	"prices":       true,
+	"currencies":   true,
+}
+
+// CustomParameters returns the parameters that are not defined by Paymentwall, such as
+// the custom pingback parameters configured for the project.
+func (p *Pingback) CustomParameters() map[string]string {
+	custom := make(map[string]string)
+	for k, v := range p.m {
+		if name, _, _ := splitArrayKey(k); !pingbackParams[name] {
+			custom[k] = v
+		}
+	}
+	return custom
+}
+
 func (p *Pingback) IsDeliverable() bool {
 	type_ := p.GetType()
 	return type_ == PingbackTypeRegular ||
@@ -196,3 +299,24 @@ func (p *Pingback) IsCancelable() bool {
 func (p *Pingback) IsUnderReview() bool {
 	return p.GetType() == PingbackTypeRiskUnderReview
 }
+
+// IsTrial reports whether the pingback is for the trial period of a subscription.
+func (p *Pingback) IsTrial() bool {
+	return p.IsDeliverable() && p.Get("is_trial") == "1"
+}
+
+// IsRenewal reports whether the pingback is for a recurring payment of a subscription,
+// i.e. a payment whose initial_ref is the reference ID of an earlier payment.
+func (p *Pingback) IsRenewal() bool {
+	initialRef := p.Get("initial_ref")
+	return p.IsDeliverable() && initialRef != "" && initialRef != p.GetReferenceID()
+}
+
+// IsSubscriptionEnded reports whether the subscription will not be renewed anymore,
+// because it was cancelled, has expired or its payments failed.
+func (p *Pingback) IsSubscriptionEnded() bool {
+	type_ := p.GetType()
+	return type_ == PingbackTypeSubscriptionCancelled ||
+		type_ == PingbackTypeSubscriptionExpired ||
+		type_ == PingbackTypeSubscriptionPaymentFailed
+}
@@ -1,6 +1,14 @@
 package paymentwall
 
-import "testing"
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
 
 func TestPingback_IsIPValid(t *testing.T) {
 	var tests = []struct {
@@ -13,7 +21,7 @@ func TestPingback_IsIPValid(t *testing.T) {
 		{"192.168.1.1", false},
 		{"216.127.72.1", false},
 		{"216.127.73.2", false},
-		{"2001:0db8:85a3:0000:0000:8a2e:0370:7334", false}, // IPv6 not supported.
+		{"2001:0db8:85a3:0000:0000:8a2e:0370:7334", false}, // Not in the default allowlist.
 
 		// All valid, within-range IPs.
 		{"216.127.71.0", true},
@@ -280,5 +288,296 @@ func TestPingback_IsIPValid(t *testing.T) {
 		if !p.IsIPValid() && test.isValid {
 			t.Errorf("IP was not within range of whitelist, got: %v", test.ip)
 		}
+		if p.IsIPValid() && !test.isValid {
+			t.Errorf("IP was unexpectedly within range of whitelist, got: %v", test.ip)
+		}
+	}
+}
+
+func TestPingback_Cart(t *testing.T) {
+	values := url.Values{
+		"uid":           {"user"},
+		"type":          {"0"},
+		"ref":           {"r1"},
+		"sig":           {"x"},
+		"sign_version":  {"2"},
+		"goodsid[0]":    {"a"},
+		"prices[0]":     {"9.99"},
+		"currencies[0]": {"USD"},
+		"goodsid[1]":    {"b"},
+		"goodsid[2]":    {"c"},
+		"prices[2]":     {"4.5"},
+	}
+	p := NewPingback(values, "216.127.71.1", API_CART, "secret")
+	if !p.IsParametersValid() {
+		t.Fatalf("unexpected error: %v", p.GetError())
+	}
+	items, err := p.GetCartItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CartItem{{"a", NewMoney(999, "USD")}, {"b", Money{}}, {"c", NewMoney(450, "")}}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("got %v, want %v", items, want)
+	}
+
+	values.Del("goodsid[0]")
+	if NewPingback(values, "216.127.71.1", API_CART, "secret").IsParametersValid() {
+		t.Error("cart pingback without goodsid[0] should be invalid")
+	}
+}
+
+func TestPingback_Check(t *testing.T) {
+	values := url.Values{
+		"uid":          {"user"},
+		"goodsid":      {"product"},
+		"type":         {"0"},
+		"ref":          {"r1"},
+		"sign_version": {SignVersion2},
+	}
+	signV2(values, "secret")
+
+	p := NewPingback(values, "216.127.71.1", API_GOODS, "secret")
+	if p.GetError() != nil {
+		t.Errorf("GetError() = %v before validation, want nil", p.GetError())
+	}
+	if err := p.Check(false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var tests = []struct {
+		values url.Values
+		ip     string
+		target error
+		kind   ValidationKind
+		param  string
+	}{
+		{url.Values{"uid": {"user"}}, "216.127.71.1", ErrorMissingParameter, ValidationMissingParameter, "type"},
+		{values, "1.2.3.4", ErrorIPNotAllowed, ValidationIPNotAllowed, ""},
+		{url.Values{
+			"uid": {"user"}, "goodsid": {"product"}, "type": {"0"}, "ref": {"r1"},
+			"sign_version": {SignVersion2}, "sig": {"bad"},
+		}, "216.127.71.1", ErrorWrongSignature, ValidationWrongSignature, ""},
+	}
+	for _, test := range tests {
+		p := NewPingback(test.values, test.ip, API_GOODS, "secret")
+		err := p.Check(false)
+		if !errors.Is(err, test.target) {
+			t.Errorf("got %v, want %v", err, test.target)
+		}
+		var verr *ValidationError
+		if !errors.As(err, &verr) || verr.Kind != test.kind || verr.Param != test.param {
+			t.Errorf("got %#v, want kind %v and param %q", err, test.kind, test.param)
+		}
+		if p.Validate(false) || p.GetError() == nil {
+			t.Errorf("Validate should fail with %v", test.target)
+		}
+	}
+}
+
+func TestPingback_SignVersion1(t *testing.T) {
+	values := url.Values{
+		"uid":      {"user"},
+		"currency": {"100"},
+		"type":     {"0"},
+		"ref":      {"r1"},
+		"extra":    {"not signed in version 1"},
+	}
+	sum := md5.Sum([]byte("uid=usercurrency=100type=0ref=r1secret"))
+	values.Set("sig", hex.EncodeToString(sum[:]))
+
+	p := NewPingback(values, "216.127.71.1", API_VC, "secret")
+	if err := p.Check(false); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	values.Set("sign_version", "4")
+	p = NewPingback(values, "216.127.71.1", API_VC, "secret")
+	if err := p.Check(false); !errors.Is(err, ErrorUnknownSignVersion) {
+		t.Errorf("got %v, want %v", err, ErrorUnknownSignVersion)
+	}
+
+	// Only VC pingbacks may omit sign_version.
+	goods := url.Values{
+		"uid":     {"user"},
+		"goodsid": {"product"},
+		"type":    {"0"},
+		"ref":     {"r1"},
+		"is_test": {"1"},
+	}
+	sum = md5.Sum([]byte("uid=usergoodsid=productslength=speriod=type=0ref=r1secret"))
+	goods.Set("sig", hex.EncodeToString(sum[:]))
+	p = NewPingback(goods, "216.127.71.1", API_GOODS, "secret")
+	if err := p.Check(false); !errors.Is(err, ErrorMissingParameter) {
+		t.Errorf("got %v, want %v", err, ErrorMissingParameter)
+	}
+}
+
+func TestPingback_TamperedSignature(t *testing.T) {
+	for _, version := range []string{SignVersion1, SignVersion2, SignVersion3} {
+		values := url.Values{
+			"uid":          {"user"},
+			"goodsid":      {"product"},
+			"slength":      {"1"},
+			"speriod":      {"month"},
+			"type":         {"0"},
+			"ref":          {"r1"},
+			"sign_version": {version},
+		}
+		sig, err := NewPingbackSigner(API_GOODS, "secret", version).Sign(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var tests = []struct {
+			name  string
+			key   string
+			value string
+			valid bool
+		}{
+			{"original", "sig", sig, true},
+			{"upper-case", "sig", strings.ToUpper(sig), true},
+			{"flipped digit", "sig", flipHexDigit(sig), false},
+			{"truncated", "sig", sig[:len(sig)-2], false},
+			{"not hex", "sig", strings.Repeat("z", len(sig)), false},
+			{"empty", "sig", "", false},
+			{"wrong uid", "uid", "other", false},
+			{"wrong type", "type", "2", false},
+			{"wrong ref", "ref", "r2", false},
+		}
+		for _, test := range tests {
+			tampered := url.Values{}
+			for k, v := range values {
+				tampered[k] = v
+			}
+			tampered.Set("sig", sig)
+			tampered.Set(test.key, test.value)
+
+			p := NewPingback(tampered, "216.127.71.1", API_GOODS, "secret")
+			if valid := p.IsSignatureValid(); valid != test.valid {
+				t.Errorf("version %s, %s: got valid %v, want %v", version, test.name, valid, test.valid)
+			}
+		}
+	}
+}
+
+func flipHexDigit(sig string) string {
+	b := []byte(sig)
+	if b[0] == '0' {
+		b[0] = '1'
+	} else {
+		b[0] = '0'
+	}
+	return string(b)
+}
+
+func TestPingback_RepeatedArrayParams(t *testing.T) {
+	values := url.Values{
+		"uid":          {"user"},
+		"goodsid[]":    {"a", "b"},
+		"type":         {"0"},
+		"ref":          {"r1"},
+		"sign_version": {SignVersion2},
+	}
+	sum := md5.Sum([]byte("goodsid[0]=agoodsid[1]=bref=r1sign_version=2type=0uid=usersecret"))
+	values.Set("sig", hex.EncodeToString(sum[:]))
+
+	p := NewPingback(values, "216.127.71.1", API_CART, "secret")
+	if err := p.Check(false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	items, err := p.GetCartItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].ProductID != "a" || items[1].ProductID != "b" {
+		t.Errorf("got items %v", items)
+	}
+}
+
+func TestPingback_TypedAccessors(t *testing.T) {
+	values := url.Values{
+		"uid":       {"user"},
+		"currency":  {"-150.5"},
+		"type":      {"2"},
+		"ref":       {"r1"},
+		"reason":    {"2"},
+		"slength":   {"3"},
+		"speriod":   {"month"},
+		"is_test":   {"1"},
+		"server_id": {"42"},
+	}
+	p := NewPingback(values, "216.127.71.1", API_VC, "secret")
+
+	if amount, err := p.VCAmount(); err != nil || amount != (Decimal{Units: -1505, Scale: 1}) {
+		t.Errorf("VCAmount() = %v, %v", amount, err)
+	}
+	if length, period, err := p.ProductPeriod(); err != nil || length != 3 || period != PeriodTypeMonth {
+		t.Errorf("ProductPeriod() = %v, %v, %v", length, period, err)
+	}
+	if reason := p.ChargebackReason(); reason != PingbackChargebackReason2 {
+		t.Errorf("ChargebackReason() = %v", reason)
+	}
+	if custom := p.CustomParameters(); !reflect.DeepEqual(custom, map[string]string{"server_id": "42"}) {
+		t.Errorf("CustomParameters() = %v", custom)
+	}
+
+	var v struct {
+		UID      string           `pingback:"uid"`
+		Amount   float64          `pingback:"currency"`
+		Type     PingbackType     `pingback:"type"`
+		Reason   ChargebackReason `pingback:"reason"`
+		Length   uint             `pingback:"slength"`
+		IsTest   bool             `pingback:"is_test"`
+		ServerID int              `pingback:"server_id"`
+		Missing  string           `pingback:"missing"`
+		Ignored  string
+	}
+	if err := p.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.UID != "user" || v.Amount != -150.5 || v.Type != PingbackTypeNegative ||
+		v.Reason != PingbackChargebackReason2 || v.Length != 3 || !v.IsTest || v.ServerID != 42 {
+		t.Errorf("Decode() = %+v", v)
+	}
+
+	var bad struct {
+		UID int `pingback:"uid"`
+	}
+	if err := p.Decode(&bad); err == nil {
+		t.Error("expected error decoding uid into int")
+	}
+	if err := p.Decode(v); err != ErrorInvalidDecodeTarget {
+		t.Errorf("got %v, want %v", err, ErrorInvalidDecodeTarget)
+	}
+}
+
+func TestChargebackReason(t *testing.T) {
+	var tests = []struct {
+		reason  ChargebackReason
+		str     string
+		fraud   bool
+		ban     bool
+		partial bool
+	}{
+		{PingbackChargebackReason1, "Chargeback", false, false, false},
+		{PingbackChargebackReason2, "Credit Card fraud", true, true, false},
+		{PingbackChargebackReason3, "Other fraud", true, true, false},
+		{PingbackChargebackReason5, "Fake / Proxy user", true, false, false},
+		{PingbackChargebackReason10, "Partially reversed transaction", false, false, true},
+		{"99", "Unknown reason 99", false, false, false},
+	}
+	for _, test := range tests {
+		r := test.reason
+		if r.String() != test.str || r.IsFraud() != test.fraud ||
+			r.RecommendsBan() != test.ban || r.IsPartialReversal() != test.partial {
+			t.Errorf("reason %s: got %q fraud=%v ban=%v partial=%v", string(r),
+				r.String(), r.IsFraud(), r.RecommendsBan(), r.IsPartialReversal())
+		}
+	}
+
+	p := NewPingback(url.Values{"reason": {"3"}}, "", API_GOODS, "")
+	if !p.Reason().RecommendsBan() {
+		t.Errorf("Reason() = %v", p.Reason())
 	}
 }
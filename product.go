@@ -20,13 +20,12 @@ const (
 
 func NewProduct(
 	name, id string,
-	amount float64, currency string,
+	amount Money,
 	pType ProductType) *Product {
 	p := &Product{
 		Name:     name,
 		Identity: id,
 		Amount:   amount,
-		Currency: currency,
 		Type:     pType,
 	}
 	return p
@@ -36,14 +35,32 @@ type Product struct {
 	Name     string
 	Identity string
 
-	Amount   float64
-	Currency string
+	Amount Money
 
 	Type ProductType
 
 	Recurring    bool
 	PeriodLength uint
 	PeriodType   PeriodType
+
+	Trial *Trial
+}
+
+// Trial is the first period of a subscription, charged at its own price
+// before the product's regular price applies.
+type Trial struct {
+	Amount Money
+
+	PeriodLength uint
+	PeriodType   PeriodType
+}
+
+func (t *Trial) DisplayAmount() string {
+	return t.Amount.Decimal()
+}
+
+func (t *Trial) DisplayPeriodLength() string {
+	return strconv.FormatUint(uint64(t.PeriodLength), 10)
 }
 
 func (p *Product) SetSubscription(
@@ -55,8 +72,20 @@ func (p *Product) SetSubscription(
 	p.PeriodType = periodType
 }
 
+// SetTrial sets a trial period to a subscription product.
+func (p *Product) SetTrial(
+	amount Money,
+	periodLength uint,
+	periodType PeriodType) {
+	p.Trial = &Trial{
+		Amount:       amount,
+		PeriodLength: periodLength,
+		PeriodType:   periodType,
+	}
+}
+
 func (p *Product) DisplayAmount() string {
-	return strconv.FormatFloat(p.Amount, 'f', -1, 64)
+	return p.Amount.Decimal()
 }
 
 func (p *Product) DisplayPeriodLength() string {
@@ -0,0 +1,86 @@
+package paymentwall
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrorUnhandledPingbackType = errors.New("unhandled pingback type")
+
+// PingbackFunc processes a validated pingback.
+type PingbackFunc func(p *Pingback) error
+
+// NewRouter returns a Router without any handler.
+func NewRouter() *Router {
+	return &Router{
+		handlers: make(map[PingbackType]PingbackFunc),
+	}
+}
+
+// Router dispatches each validated pingback to the handler registered for its type.
+type Router struct {
+	handlers map[PingbackType]PingbackFunc
+	fallback PingbackFunc
+}
+
+// On registers f for pingbacks of type t, replacing any handler registered before.
+func (r *Router) On(t PingbackType, f PingbackFunc) {
+	r.handlers[t] = f
+}
+
+func (r *Router) OnRegular(f PingbackFunc) {
+	r.On(PingbackTypeRegular, f)
+}
+
+func (r *Router) OnGoodwill(f PingbackFunc) {
+	r.On(PingbackTypeGoodwill, f)
+}
+
+func (r *Router) OnNegative(f PingbackFunc) {
+	r.On(PingbackTypeNegative, f)
+}
+
+func (r *Router) OnRiskUnderReview(f PingbackFunc) {
+	r.On(PingbackTypeRiskUnderReview, f)
+}
+
+func (r *Router) OnRiskAccepted(f PingbackFunc) {
+	r.On(PingbackTypeRiskReviewedAccepted, f)
+}
+
+func (r *Router) OnRiskDeclined(f PingbackFunc) {
+	r.On(PingbackTypeRiskReviewedDeclined, f)
+}
+
+func (r *Router) OnAuthorizationVoided(f PingbackFunc) {
+	r.On(PingbackTypeRiskAuthorizationVoided, f)
+}
+
+func (r *Router) OnSubscriptionCancelled(f PingbackFunc) {
+	r.On(PingbackTypeSubscriptionCancelled, f)
+}
+
+func (r *Router) OnSubscriptionExpired(f PingbackFunc) {
+	r.On(PingbackTypeSubscriptionExpired, f)
+}
+
+func (r *Router) OnSubscriptionPaymentFailed(f PingbackFunc) {
+	r.On(PingbackTypeSubscriptionPaymentFailed, f)
+}
+
+// Fallback registers f for the pingback types without a handler of their own.
+func (r *Router) Fallback(f PingbackFunc) {
+	r.fallback = f
+}
+
+// Dispatch calls the handler registered for the type of p, or the fallback. Without
+// either, it returns an error matching ErrorUnhandledPingbackType.
+func (r *Router) Dispatch(p *Pingback) error {
+	if f, ok := r.handlers[p.GetType()]; ok {
+		return f(p)
+	}
+	if r.fallback != nil {
+		return r.fallback(p)
+	}
+	return fmt.Errorf("%w: %q", ErrorUnhandledPingbackType, string(p.GetType()))
+}
@@ -0,0 +1,53 @@
+package paymentwall
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	var called string
+	handler := func(name string) PingbackFunc {
+		return func(p *Pingback) error {
+			called = name
+			return nil
+		}
+	}
+
+	r := NewRouter()
+	r.OnRegular(handler("regular"))
+	r.OnNegative(handler("negative"))
+	r.OnAuthorizationVoided(handler("voided"))
+	r.OnSubscriptionExpired(handler("expired"))
+
+	var tests = []struct {
+		type_  PingbackType
+		called string
+	}{
+		{PingbackTypeRegular, "regular"},
+		{PingbackTypeNegative, "negative"},
+		{PingbackTypeRiskAuthorizationVoided, "voided"},
+		{PingbackTypeSubscriptionExpired, "expired"},
+	}
+	for _, test := range tests {
+		called = ""
+		p := NewPingback(url.Values{"type": {string(test.type_)}}, "", API_GOODS, "")
+		if err := r.Dispatch(p); err != nil {
+			t.Errorf("type %s: unexpected error: %v", test.type_, err)
+		}
+		if called != test.called {
+			t.Errorf("type %s: got %q, want %q", test.type_, called, test.called)
+		}
+	}
+
+	unknown := NewPingback(url.Values{"type": {"999"}}, "", API_GOODS, "")
+	if err := r.Dispatch(unknown); !errors.Is(err, ErrorUnhandledPingbackType) {
+		t.Errorf("got %v, want %v", err, ErrorUnhandledPingbackType)
+	}
+
+	r.Fallback(handler("fallback"))
+	if err := r.Dispatch(unknown); err != nil || called != "fallback" {
+		t.Errorf("got %v and %q, want fallback", err, called)
+	}
+}
@@ -0,0 +1,216 @@
+package paymentwall
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Signer calculates and verifies the signatures of widget calls, pingbacks and API requests.
+// https://docs.paymentwall.com/reference/signature-calculation
+type Signer struct {
+	SecretKey string
+	Version   string
+
+	// Fields lists, in order, the parameters covered by a version 1 signature.
+	// When empty, version 1 signs the uid alone, as done for widget calls.
+	Fields []string
+
+	// SignatureParam is the parameter carrying the signature, which is left out of the
+	// signature: sig for pingbacks. Every parameter is signed when it is empty.
+	SignatureParam string
+}
+
+// NewPingbackSigner returns a Signer for pingbacks of the given ApiType.
+func NewPingbackSigner(apiType ApiType, secretKey, version string) *Signer {
+	s := &Signer{
+		SecretKey:      secretKey,
+		Version:        version,
+		SignatureParam: "sig",
+	}
+	switch apiType {
+	case API_VC:
+		s.Fields = []string{"uid", "currency", "type", "ref"}
+	case API_GOODS:
+		s.Fields = []string{"uid", "goodsid", "slength", "speriod", "type", "ref"}
+	default:
+		s.Fields = []string{"uid", "goodsid", "type", "ref"}
+	}
+	return s
+}
+
+// Sign calculates the signature of params, in lower-case hex. Every parameter is signed,
+// so remove the sign parameter of a widget call first. Use NewPingbackSigner for pingbacks.
+func Sign(params url.Values, secretKey, version string) (string, error) {
+	return (&Signer{SecretKey: secretKey, Version: version}).Sign(params)
+}
+
+// Verify checks sig against the signature of params. It returns ErrorWrongSignature if
+// they do not match and ErrorUnknownSignVersion for an unsupported version.
+func Verify(params url.Values, sig, secretKey, version string) error {
+	return (&Signer{SecretKey: secretKey, Version: version}).Verify(params, sig)
+}
+
+func (s *Signer) Sign(params url.Values) (string, error) {
+	sum, err := s.sum(params)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// Verify checks sig against the signature of params in constant time.
+// The hex digits of sig may be in upper or lower case.
+func (s *Signer) Verify(params url.Values, sig string) error {
+	expected, err := s.sum(params)
+	if err != nil {
+		return err
+	}
+	actual, err := hex.DecodeString(sig)
+	if err != nil {
+		return ErrorWrongSignature
+	}
+	if subtle.ConstantTimeCompare(expected, actual) != 1 {
+		return ErrorWrongSignature
+	}
+	return nil
+}
+
+func (s *Signer) sum(params url.Values) ([]byte, error) {
+	var h hash.Hash
+	switch s.Version {
+	case SignVersion1, SignVersion2:
+		h = md5.New()
+	case SignVersion3:
+		h = sha256.New()
+	default:
+		return nil, ErrorUnknownSignVersion
+	}
+
+	h.Write([]byte(s.baseString(params) + s.SecretKey))
+	return h.Sum(nil), nil
+}
+
+// baseString follows the rules of Paymentwall's libraries, which sign parameters as they are
+// decoded by PHP: an array parameter such as prices[0], prices[1], ... is sorted with the other
+// parameters by its name, then its elements are signed in the order of their indexes, numeric
+// indexes being compared as numbers.
+func (s *Signer) baseString(params url.Values) string {
+	var b strings.Builder
+	if s.Version == SignVersion1 {
+		if len(s.Fields) == 0 {
+			return params.Get("uid")
+		}
+		signParams := flattenParams(params)
+		for _, field := range s.Fields {
+			var found bool
+			for _, p := range signParams {
+				if p.name == field {
+					b.WriteString(p.String())
+					found = true
+				}
+			}
+			if !found {
+				fmt.Fprintf(&b, "%s=", field)
+			}
+		}
+		return b.String()
+	}
+
+	for _, p := range flattenParams(params) {
+		if s.SignatureParam != "" && p.name == s.SignatureParam && !p.array {
+			continue
+		}
+		b.WriteString(p.String())
+	}
+	return b.String()
+}
+
+// signParam is a parameter, or an element of an array parameter, to be signed.
+type signParam struct {
+	name  string
+	index string // The index of an array element, empty for plain parameters.
+	array bool
+	value string
+}
+
+func (p signParam) String() string {
+	if p.array {
+		return fmt.Sprintf("%s[%s]=%s", p.name, p.index, p.value)
+	}
+	return fmt.Sprintf("%s=%s", p.name, p.value)
+}
+
+// flattenParams returns the parameters in signing order. The values of a repeated
+// name[] parameter become the elements 0, 1, ... of the array, and only the last
+// value of another repeated parameter is kept.
+func flattenParams(params url.Values) []signParam {
+	signParams := make([]signParam, 0, len(params))
+	for k, vs := range params {
+		if len(vs) == 0 {
+			continue
+		}
+		name, index, array := splitArrayKey(k)
+		if array && index == "" {
+			for i, v := range vs {
+				signParams = append(signParams, signParam{name, strconv.Itoa(i), true, v})
+			}
+			continue
+		}
+		signParams = append(signParams, signParam{name, index, array, vs[len(vs)-1]})
+	}
+
+	sort.Slice(signParams, func(i, j int) bool {
+		a, b := signParams[i], signParams[j]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if a.array != b.array {
+			return !a.array
+		}
+		return lessIndex(a.index, b.index)
+	})
+	return signParams
+}
+
+// splitArrayKey splits an array parameter key such as "prices[10]" into its name and index.
+func splitArrayKey(k string) (name, index string, array bool) {
+	i := strings.IndexByte(k, '[')
+	if i <= 0 || !strings.HasSuffix(k, "]") {
+		return k, "", false
+	}
+	return k[:i], k[i+1 : len(k)-1], true
+}
+
+// expandArrayParams returns values with each repeated name[] parameter replaced
+// by the indexed elements name[0], name[1], ...
+func expandArrayParams(values url.Values) url.Values {
+	expanded := make(url.Values, len(values))
+	for k, vs := range values {
+		if name, index, array := splitArrayKey(k); array && index == "" {
+			for i, v := range vs {
+				expanded.Set(fmt.Sprintf("%s[%d]", name, i), v)
+			}
+			continue
+		}
+		expanded[k] = vs
+	}
+	return expanded
+}
+
+// lessIndex compares array indexes, as numbers when both are integers.
+func lessIndex(a, b string) bool {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		return na < nb
+	}
+	return a < b
+}
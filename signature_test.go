@@ -0,0 +1,116 @@
+package paymentwall
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	params := url.Values{
+		"uid": {"user"},
+		"key": {"app"},
+	}
+	md5Sum := md5.Sum([]byte("key=appuid=usersecret"))
+	sha256Sum := sha256.Sum256([]byte("key=appuid=usersecret"))
+	v1Sum := md5.Sum([]byte("usersecret"))
+
+	var tests = []struct {
+		version string
+		sig     string
+	}{
+		{SignVersion1, hex.EncodeToString(v1Sum[:])},
+		{SignVersion2, hex.EncodeToString(md5Sum[:])},
+		{SignVersion3, hex.EncodeToString(sha256Sum[:])},
+	}
+	for _, test := range tests {
+		sig, err := Sign(params, "secret", test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sig != test.sig {
+			t.Errorf("version %s: got %s, want %s", test.version, sig, test.sig)
+		}
+		if err := Verify(params, sig, "secret", test.version); err != nil {
+			t.Errorf("version %s: unexpected error: %v", test.version, err)
+		}
+		if err := Verify(params, sig, "other", test.version); err != ErrorWrongSignature {
+			t.Errorf("version %s: got %v, want %v", test.version, err, ErrorWrongSignature)
+		}
+	}
+
+	if _, err := Sign(params, "secret", "4"); err != ErrorUnknownSignVersion {
+		t.Errorf("got %v, want %v", err, ErrorUnknownSignVersion)
+	}
+}
+
+func TestPingbackSigner_Version1(t *testing.T) {
+	params := url.Values{
+		"uid":        {"user"},
+		"goodsid[0]": {"a"},
+		"goodsid[1]": {"b"},
+		"type":       {"0"},
+		"ref":        {"r1"},
+		"extra":      {"x"},
+	}
+	sum := md5.Sum([]byte("uid=usergoodsid[0]=agoodsid[1]=btype=0ref=r1secret"))
+	sig, err := NewPingbackSigner(API_CART, "secret", SignVersion1).Sign(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig != hex.EncodeToString(sum[:]) {
+		t.Errorf("got %s, want %s", sig, hex.EncodeToString(sum[:]))
+	}
+}
+
+func TestPingbackSigner_SignParam(t *testing.T) {
+	// A custom pingback parameter named sign is signed, only sig is left out.
+	params := url.Values{
+		"uid":          {"user"},
+		"goodsid":      {"product"},
+		"type":         {"0"},
+		"ref":          {"r1"},
+		"sign":         {"custom"},
+		"sign_version": {SignVersion2},
+		"sig":          {"ignored"},
+	}
+	sum := md5.Sum([]byte("goodsid=productref=r1sign=customsign_version=2type=0uid=usersecret"))
+	sig, err := NewPingbackSigner(API_GOODS, "secret", SignVersion2).Sign(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig != hex.EncodeToString(sum[:]) {
+		t.Errorf("got %s, want %s", sig, hex.EncodeToString(sum[:]))
+	}
+}
+
+func TestSign_ArrayParams(t *testing.T) {
+	params := url.Values{}
+	expected := "a=1"
+	for i := 0; i < 12; i++ {
+		params.Set(fmt.Sprintf("external_ids[%d]", i), fmt.Sprintf("p%d", i))
+		expected += fmt.Sprintf("external_ids[%d]=p%d", i, i)
+	}
+	params.Set("a", "1")
+	params.Set("external_ids_note", "x")
+	params.Set("external_idsz", "y")
+	params.Set("z", "2")
+	expected += "external_ids_note=xexternal_idsz=yz=2"
+
+	signer := &Signer{SecretKey: "secret", Version: SignVersion2}
+	if base := signer.baseString(params); base != expected {
+		t.Errorf("got base string\n%s\nwant\n%s", base, expected)
+	}
+
+	// Repeated name[] values are signed as indexed elements, other repeated values by their last value.
+	repeated := url.Values{
+		"goodsid[]": {"a", "b"},
+		"uid":       {"first", "last"},
+	}
+	if base := signer.baseString(repeated); base != "goodsid[0]=agoodsid[1]=buid=last" {
+		t.Errorf("got base string %s", base)
+	}
+}
@@ -0,0 +1,190 @@
+package paymentwall
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrorPingbackInProgress is returned by the PingbackHandler for a pingback that another
+// request is still processing. The handler answers it with 409 Conflict, so Paymentwall
+// retries the pingback later.
+var ErrorPingbackInProgress = errors.New("pingback is being processed")
+
+// ClaimState is the state of a pingback in a ReferenceStore, as seen by Claim.
+type ClaimState int
+
+const (
+	ClaimAcquired   ClaimState = iota // The pingback was claimed for the caller, who processes it.
+	ClaimInProgress                   // Another request is processing the pingback.
+	ClaimCompleted                    // The pingback has been processed.
+)
+
+// ReferenceStore claims pingbacks before they are processed, so that pingbacks retried by
+// Paymentwall are acknowledged without being processed twice. A pingback is identified by
+// its reference ID together with its type, since e.g. a chargeback reuses the reference ID
+// of the payment it reverses.
+type ReferenceStore interface {
+	// Claim atomically claims the pingback, even when the store is shared by several
+	// processes. Only the caller that gets ClaimAcquired may process the pingback.
+	Claim(ref string, t PingbackType) (ClaimState, error)
+	// Complete records a claimed pingback as processed.
+	Complete(ref string, t PingbackType) error
+	// Release releases the claim of a pingback whose processing failed, so that it is
+	// processed when Paymentwall retries it.
+	Release(ref string, t PingbackType) error
+}
+
+func referenceKey(ref string, t PingbackType) string {
+	return string(t) + " " + ref
+}
+
+// NewMemoryReferenceStore returns a ReferenceStore that keeps the claims in memory.
+// They are lost when the process exits.
+func NewMemoryReferenceStore() *MemoryReferenceStore {
+	return &MemoryReferenceStore{
+		refs: make(map[string]ClaimState),
+	}
+}
+
+type MemoryReferenceStore struct {
+	mu   sync.Mutex
+	refs map[string]ClaimState
+}
+
+func (s *MemoryReferenceStore) Claim(ref string, t PingbackType) (ClaimState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := referenceKey(ref, t)
+	if state, ok := s.refs[key]; ok {
+		return state, nil
+	}
+	s.refs[key] = ClaimInProgress
+	return ClaimAcquired, nil
+}
+
+func (s *MemoryReferenceStore) Complete(ref string, t PingbackType) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs[referenceKey(ref, t)] = ClaimCompleted
+	return nil
+}
+
+func (s *MemoryReferenceStore) Release(ref string, t PingbackType) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := referenceKey(ref, t)
+	if s.refs[key] == ClaimInProgress {
+		delete(s.refs, key)
+	}
+	return nil
+}
+
+// NewFileReferenceStore returns a ReferenceStore that keeps its claims as files in the
+// directory dir, which is created if needed. The files are created exclusively, so the
+// directory may be shared by several processes, e.g. on a network file system.
+func NewFileReferenceStore(dir string) (*FileReferenceStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileReferenceStore{dir: dir}, nil
+}
+
+type FileReferenceStore struct {
+	dir string
+
+	// StaleAfter, when set, is the time after which a claim that is still in progress is
+	// taken over by the next Claim, e.g. because the process holding it crashed. It must
+	// be longer than the processing of a pingback may take.
+	StaleAfter time.Duration
+}
+
+// path returns the file claiming the pingback. The key is hashed, as reference IDs may
+// contain characters that are not allowed in file names. The file of a completed claim
+// has the suffix ".done".
+func (s *FileReferenceStore) path(ref string, t PingbackType) string {
+	sum := sha256.Sum256([]byte(referenceKey(ref, t)))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
+
+func (s *FileReferenceStore) Claim(ref string, t PingbackType) (ClaimState, error) {
+	path := s.path(ref, t)
+	if _, err := os.Stat(path + ".done"); err == nil {
+		return ClaimCompleted, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+
+	acquired, err := s.create(path, referenceKey(ref, t))
+	if err != nil {
+		return 0, err
+	}
+	if acquired {
+		// Another process may have completed the pingback since it was looked up.
+		if _, err := os.Stat(path + ".done"); err == nil {
+			os.Remove(path)
+			return ClaimCompleted, nil
+		}
+		return ClaimAcquired, nil
+	}
+	if s.StaleAfter <= 0 || !s.isStale(path) {
+		return ClaimInProgress, nil
+	}
+	// Only one of the processes taking over a stale claim succeeds in moving it away.
+	if err := os.Rename(path, path+".stale."+randomSuffix()); err != nil {
+		return ClaimInProgress, nil
+	}
+	if acquired, err := s.create(path, referenceKey(ref, t)); err != nil || !acquired {
+		return ClaimInProgress, err
+	}
+	return ClaimAcquired, nil
+}
+
+// create creates the claim file at path, and reports whether it did not exist yet.
+func (s *FileReferenceStore) create(path, key string) (bool, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	_, err = f.WriteString(key + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *FileReferenceStore) isStale(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) > s.StaleAfter
+}
+
+func randomSuffix() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *FileReferenceStore) Complete(ref string, t PingbackType) error {
+	path := s.path(ref, t)
+	return os.Rename(path, path+".done")
+}
+
+func (s *FileReferenceStore) Release(ref string, t PingbackType) error {
+	err := os.Remove(s.path(ref, t))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
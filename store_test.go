@@ -0,0 +1,74 @@
+package paymentwall
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileReferenceStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "refs")
+	s, err := NewFileReferenceStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state, err := s.Claim("r1", PingbackTypeRegular); err != nil || state != ClaimAcquired {
+		t.Fatalf("Claim = %v, %v, want acquired", state, err)
+	}
+	if _, err := s.Claim("../r2\n", PingbackTypeRegular); err != nil {
+		t.Errorf("unexpected error for reference ID with special characters: %v", err)
+	}
+
+	// Another process sharing the directory.
+	other, err := NewFileReferenceStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		ref   string
+		type_ PingbackType
+		state ClaimState
+	}{
+		{"r1", PingbackTypeRegular, ClaimInProgress},
+		{"r1", PingbackTypeNegative, ClaimAcquired},
+		{"r2", PingbackTypeRegular, ClaimAcquired},
+	}
+	for _, test := range tests {
+		state, err := other.Claim(test.ref, test.type_)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state != test.state {
+			t.Errorf("Claim(%s, %s) = %v, want %v", test.ref, test.type_, state, test.state)
+		}
+	}
+
+	if err := s.Complete("r1", PingbackTypeRegular); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := other.Claim("r1", PingbackTypeRegular); state != ClaimCompleted {
+		t.Errorf("completed pingback: got %v, want %v", state, ClaimCompleted)
+	}
+
+	if err := other.Release("r2", PingbackTypeRegular); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := s.Claim("r2", PingbackTypeRegular); state != ClaimAcquired {
+		t.Errorf("released pingback: got %v, want %v", state, ClaimAcquired)
+	}
+
+	// The claim of a crashed process is taken over once it is stale.
+	other.StaleAfter = time.Minute
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(s.path("r2", PingbackTypeRegular), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := other.Claim("r2", PingbackTypeRegular); state != ClaimAcquired {
+		t.Errorf("stale claim: got %v, want %v", state, ClaimAcquired)
+	}
+	if state, _ := other.Claim("r2", PingbackTypeRegular); state != ClaimInProgress {
+		t.Errorf("taken over claim: got %v, want %v", state, ClaimInProgress)
+	}
+}
@@ -0,0 +1,204 @@
+// Package subscription computes the state of a Paymentwall subscription from its pingbacks.
+package subscription
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sanae10001/paymentwall-go"
+)
+
+type State int
+
+const (
+	StateNone      State = iota // No payment has been delivered yet.
+	StateTrial                  // The trial period has been paid.
+	StateActive                 // A regular period has been paid.
+	StatePastDue                // A renewal payment failed.
+	StateCancelled              // Cancelled by the user, active until the end of the paid period.
+	StateExpired                // Ended, the user has no access anymore.
+)
+
+func (s State) String() string {
+	switch s {
+	case StateNone:
+		return "none"
+	case StateTrial:
+		return "trial"
+	case StateActive:
+		return "active"
+	case StatePastDue:
+		return "past-due"
+	case StateCancelled:
+		return "cancelled"
+	case StateExpired:
+		return "expired"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// HasAccess reports whether the user should have access to the product in this state.
+func (s State) HasAccess() bool {
+	return s == StateTrial || s == StateActive || s == StateCancelled
+}
+
+var (
+	ErrorIllegalTransition = errors.New("illegal subscription transition")
+	ErrorOtherSubscription = errors.New("pingback is for another subscription")
+)
+
+// TransitionError is returned when a pingback is not expected in the current state.
+// It matches ErrorIllegalTransition.
+type TransitionError struct {
+	From State
+	Type paymentwall.PingbackType
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("illegal subscription transition from %s on pingback type %s", e.From, e.Type)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrorIllegalTransition
+}
+
+type event int
+
+const (
+	eventTrial event = iota
+	eventPayment
+	eventReview
+	eventReversal
+	eventCancel
+	eventExpire
+	eventPaymentFailed
+)
+
+var transitions = map[State]map[event]State{
+	StateNone: {
+		eventTrial:    StateTrial,
+		eventPayment:  StateActive,
+		eventReview:   StateNone,
+		eventReversal: StateNone, // e.g. declined after a risk review
+	},
+	StateTrial: {
+		eventPayment:       StateActive,
+		eventReview:        StateTrial,
+		eventReversal:      StateExpired,
+		eventCancel:        StateCancelled,
+		eventExpire:        StateExpired,
+		eventPaymentFailed: StatePastDue,
+	},
+	StateActive: {
+		eventPayment:       StateActive,
+		eventReview:        StateActive,
+		eventReversal:      StateExpired,
+		eventCancel:        StateCancelled,
+		eventExpire:        StateExpired,
+		eventPaymentFailed: StatePastDue,
+	},
+	StatePastDue: {
+		eventPayment:       StateActive,
+		eventReview:        StatePastDue,
+		eventReversal:      StateExpired,
+		eventCancel:        StateExpired,
+		eventExpire:        StateExpired,
+		eventPaymentFailed: StatePastDue,
+	},
+	StateCancelled: {
+		eventReview:   StateCancelled,
+		eventReversal: StateExpired,
+		eventCancel:   StateCancelled,
+		eventExpire:   StateExpired,
+	},
+	StateExpired: {
+		eventReversal: StateExpired,
+		eventExpire:   StateExpired,
+	},
+}
+
+func pingbackEvent(p *paymentwall.Pingback) (event, bool) {
+	switch {
+	case p.IsTrial():
+		return eventTrial, true
+	case p.IsDeliverable():
+		return eventPayment, true
+	case p.IsUnderReview():
+		return eventReview, true
+	case p.IsCancelable():
+		return eventReversal, true
+	}
+
+	switch p.GetType() {
+	case paymentwall.PingbackTypeRiskAuthorizationVoided:
+		return eventReversal, true
+	case paymentwall.PingbackTypeSubscriptionCancelled:
+		return eventCancel, true
+	case paymentwall.PingbackTypeSubscriptionExpired:
+		return eventExpire, true
+	case paymentwall.PingbackTypeSubscriptionPaymentFailed:
+		return eventPaymentFailed, true
+	}
+	return 0, false
+}
+
+// New returns a subscription of the user to the product, in StateNone.
+func New(uid, productID string) *Subscription {
+	return &Subscription{
+		UID:       uid,
+		ProductID: productID,
+		state:     StateNone,
+		applied:   make(map[string]struct{}),
+	}
+}
+
+// Replay returns the subscription after applying pingbacks in order.
+func Replay(uid, productID string, pingbacks []*paymentwall.Pingback) (*Subscription, error) {
+	s := New(uid, productID)
+	for _, p := range pingbacks {
+		if err := s.Apply(p); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// Subscription is the state of a subscription of one user to one product.
+// It is not safe for concurrent use.
+type Subscription struct {
+	UID       string
+	ProductID string
+
+	state State
+	// applied holds the type and reference ID of the pingbacks applied, so that the
+	// pingbacks retried by Paymentwall are ignored.
+	applied map[string]struct{}
+}
+
+func (s *Subscription) State() State {
+	return s.state
+}
+
+// Apply moves the subscription to the state following the validated pingback p.
+// The state is left unchanged on error, and when p has already been applied.
+func (s *Subscription) Apply(p *paymentwall.Pingback) error {
+	if p.GetUID() != s.UID || p.GetProductID() != s.ProductID {
+		return ErrorOtherSubscription
+	}
+	key := string(p.GetType()) + " " + p.GetReferenceID()
+	if _, ok := s.applied[key]; ok {
+		return nil
+	}
+
+	e, ok := pingbackEvent(p)
+	if !ok {
+		return &TransitionError{From: s.state, Type: p.GetType()}
+	}
+	next, ok := transitions[s.state][e]
+	if !ok {
+		return &TransitionError{From: s.state, Type: p.GetType()}
+	}
+	s.state = next
+	s.applied[key] = struct{}{}
+	return nil
+}
@@ -0,0 +1,100 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/sanae10001/paymentwall-go"
+)
+
+var refs int
+
+// pingback returns a pingback with a new reference ID, unless extra sets ref.
+func pingback(type_ paymentwall.PingbackType, extra ...string) *paymentwall.Pingback {
+	refs++
+	values := url.Values{
+		"uid":     {"user"},
+		"goodsid": {"monthly"},
+		"type":    {string(type_)},
+		"ref":     {fmt.Sprintf("r%d", refs)},
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		values.Set(extra[i], extra[i+1])
+	}
+	return paymentwall.NewPingback(values, "", paymentwall.API_GOODS, "")
+}
+
+func TestSubscription(t *testing.T) {
+	var tests = []struct {
+		name      string
+		pingbacks []*paymentwall.Pingback
+		state     State
+		illegal   bool
+	}{
+		{"trial then payment", []*paymentwall.Pingback{
+			pingback(paymentwall.PingbackTypeRegular, "is_trial", "1"),
+			pingback(paymentwall.PingbackTypeRegular),
+		}, StateActive, false},
+		{"retried trial", []*paymentwall.Pingback{
+			pingback(paymentwall.PingbackTypeRegular, "is_trial", "1", "ref", "t1"),
+			pingback(paymentwall.PingbackTypeRegular, "is_trial", "1", "ref", "t1"),
+		}, StateTrial, false},
+		{"retried cancellation", []*paymentwall.Pingback{
+			pingback(paymentwall.PingbackTypeRegular, "ref", "p1"),
+			pingback(paymentwall.PingbackTypeSubscriptionCancelled, "ref", "p1"),
+			pingback(paymentwall.PingbackTypeSubscriptionExpired, "ref", "p1"),
+			pingback(paymentwall.PingbackTypeSubscriptionCancelled, "ref", "p1"),
+		}, StateExpired, false},
+		{"cancelled", []*paymentwall.Pingback{
+			pingback(paymentwall.PingbackTypeRegular),
+			pingback(paymentwall.PingbackTypeSubscriptionCancelled),
+		}, StateCancelled, false},
+		{"cancelled then expired", []*paymentwall.Pingback{
+			pingback(paymentwall.PingbackTypeRegular),
+			pingback(paymentwall.PingbackTypeSubscriptionCancelled),
+			pingback(paymentwall.PingbackTypeSubscriptionExpired),
+		}, StateExpired, false},
+		{"payment failed", []*paymentwall.Pingback{
+			pingback(paymentwall.PingbackTypeRegular),
+			pingback(paymentwall.PingbackTypeSubscriptionPaymentFailed),
+		}, StatePastDue, false},
+		{"recovered", []*paymentwall.Pingback{
+			pingback(paymentwall.PingbackTypeRegular),
+			pingback(paymentwall.PingbackTypeSubscriptionPaymentFailed),
+			pingback(paymentwall.PingbackTypeRegular),
+		}, StateActive, false},
+		{"chargeback", []*paymentwall.Pingback{
+			pingback(paymentwall.PingbackTypeRegular),
+			pingback(paymentwall.PingbackTypeNegative),
+		}, StateExpired, false},
+		{"review then accepted", []*paymentwall.Pingback{
+			pingback(paymentwall.PingbackTypeRiskUnderReview),
+			pingback(paymentwall.PingbackTypeRiskReviewedAccepted),
+		}, StateActive, false},
+		{"payment after expiry", []*paymentwall.Pingback{
+			pingback(paymentwall.PingbackTypeRegular),
+			pingback(paymentwall.PingbackTypeSubscriptionExpired),
+			pingback(paymentwall.PingbackTypeRegular),
+		}, StateExpired, true},
+		{"cancel before payment", []*paymentwall.Pingback{
+			pingback(paymentwall.PingbackTypeSubscriptionCancelled),
+		}, StateNone, true},
+	}
+
+	for _, test := range tests {
+		s, err := Replay("user", "monthly", test.pingbacks)
+		if test.illegal != errors.Is(err, ErrorIllegalTransition) {
+			t.Errorf("%s: got error %v, want illegal transition %v", test.name, err, test.illegal)
+		}
+		if s.State() != test.state {
+			t.Errorf("%s: got state %s, want %s", test.name, s.State(), test.state)
+		}
+	}
+
+	s := New("other", "monthly")
+	if err := s.Apply(pingback(paymentwall.PingbackTypeRegular)); err != ErrorOtherSubscription {
+		t.Errorf("got %v, want %v", err, ErrorOtherSubscription)
+	}
+}
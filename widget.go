@@ -1,12 +1,9 @@
 package paymentwall
 
 import (
-	"crypto/md5"
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
 	"fmt"
-	"hash"
+	"html/template"
 	"net/url"
 	"sort"
 	"strconv"
@@ -15,7 +12,7 @@ import (
 )
 
 const (
-	baseUrl = "https://api.paymentwall.com/api"
+	defaultBaseUrl = "https://api.paymentwall.com/api"
 
 	VC_CONTROLLER    = "ps"
 	GOODS_CONTROLLER = "subscription"
@@ -24,24 +21,57 @@ const (
 
 var (
 	ErrorOnlyOneProductAllowed = errors.New("only one product is allowed when ApiType is API_GOODS")
+
+	ErrorMissingAppKey     = errors.New("widget app key is required")
+	ErrorMissingSecretKey  = errors.New("widget secret key is required unless the signature is skipped")
+	ErrorMissingWidgetCode = errors.New("widget code is required")
+	ErrorMissingUID        = errors.New("widget uid is required")
+	ErrorInvalidApiType    = errors.New("invalid ApiType")
 )
 
-func NewWidget(
-	appKey, secretKey string,
-	apiType ApiType,
-	uid, widgetCode, email string,
-	skipSignature bool) *Widget {
+// Config holds the settings every Widget requires.
+type Config struct {
+	AppKey     string // Project key
+	SecretKey  string
+	ApiType    ApiType
+	WidgetCode string
+
+	// SkipSignature sends the widget call unsigned, the SecretKey is not required then.
+	SkipSignature bool
+}
+
+// NewWidget returns a Widget for config with the given options applied.
+// The user ID is required, see WithUID.
+func NewWidget(config Config, opts ...WidgetOption) (*Widget, error) {
 	w := &Widget{
-		appKey:        appKey,
-		secretKey:     secretKey,
-		apiType:       apiType,
-		uid:           uid,
-		code:          widgetCode,
-		email:         email,
+		appKey:        config.AppKey,
+		secretKey:     config.SecretKey,
+		apiType:       config.ApiType,
+		code:          config.WidgetCode,
 		ps:            "all",
-		skipSignature: skipSignature,
+		skipSignature: config.SkipSignature,
+		baseUrl:       defaultBaseUrl,
+		clock:         time.Now,
 	}
-	return w
+	for _, opt := range opts {
+		if err := opt(w); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case w.appKey == "":
+		return nil, ErrorMissingAppKey
+	case w.secretKey == "" && !w.skipSignature:
+		return nil, ErrorMissingSecretKey
+	case w.apiType != API_VC && w.apiType != API_GOODS && w.apiType != API_CART:
+		return nil, ErrorInvalidApiType
+	case w.code == "":
+		return nil, ErrorMissingWidgetCode
+	case w.uid == "":
+		return nil, ErrorMissingUID
+	}
+	return w, nil
 }
 
 type Widget struct {
@@ -49,6 +79,8 @@ type Widget struct {
 	secretKey     string
 	apiType       ApiType
 	skipSignature bool
+	baseUrl       string
+	clock         func() time.Time
 
 	uid   string
 	code  string // widget code
@@ -75,6 +107,23 @@ func (w *Widget) AppendProduct(products ...Product) error {
 	return nil
 }
 
+// SetBaseURL sets the URL the widget calls are sent to, by default https://api.paymentwall.com/api,
+// e.g. to use a paymentwalltest.Server.
+func (w *Widget) SetBaseURL(baseUrl string) {
+	w.baseUrl = strings.TrimSuffix(baseUrl, "/")
+}
+
+// SetClock sets the function returning the time of the timestamp parameter, time.Now by default.
+func (w *Widget) SetClock(clock func() time.Time) {
+	w.clock = clock
+}
+
+// SetTimestamp fixes the timestamp parameter to t, so the widget URL and signature are
+// the same on every call.
+func (w *Widget) SetTimestamp(t time.Time) {
+	w.SetClock(func() time.Time { return t })
+}
+
 func (w *Widget) SetPS(ps string) {
 	w.ps = ps
 }
@@ -97,29 +146,79 @@ func (w *Widget) SetExtraParam(k, v string) {
 	w.extraParams[k] = v
 }
 
+// GetHtmlCode returns an iframe of the widget. The attributes override the defaults
+// (frameborder 0, width 750, height 800). Attribute values are HTML-escaped and written in
+// the order of their names; src, event handler and malformed attribute names are dropped.
+// It returns an empty string if the widget cannot be signed, see GetHtml for the error.
 func (w *Widget) GetHtmlCode(attributes map[string]string) string {
-	defaultAttributes := map[string]string{
+	u := w.GetUrl()
+	if u == "" {
+		return ""
+	}
+	return iframeHtml(u, attributes)
+}
+
+// GetHtml validates the widget and returns the iframe of GetHtmlCode as template.HTML,
+// to be used in html/template without being escaped again.
+func (w *Widget) GetHtml(attributes map[string]string) (template.HTML, error) {
+	u, err := w.URL()
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(iframeHtml(u, attributes)), nil
+}
+
+func iframeHtml(src string, attributes map[string]string) string {
+	allAttributes := map[string]string{
 		"frameborder": "0",
 		"width":       "750",
 		"height":      "800",
 	}
-
 	for k, v := range attributes {
-		defaultAttributes[k] = v
+		allAttributes[strings.ToLower(k)] = v
 	}
 
-	attrsSlice := make([]string, 0, len(defaultAttributes))
-	for attr, value := range defaultAttributes {
-		attrsSlice = append(attrsSlice,
-			fmt.Sprintf(`%s="%s"`, attr, value))
+	names := make([]string, 0, len(allAttributes))
+	for name := range allAttributes {
+		if isSafeAttributeName(name) {
+			names = append(names, name)
+		}
 	}
+	sort.Strings(names)
 
-	return fmt.Sprintf(`<iframe src="%s" %s></iframe>`,
-		w.GetUrl(), strings.Join(attrsSlice, " "))
+	var b strings.Builder
+	b.WriteString(`<iframe src="`)
+	b.WriteString(template.HTMLEscapeString(src))
+	b.WriteString(`"`)
+	for _, name := range names {
+		fmt.Fprintf(&b, ` %s="%s"`, name, template.HTMLEscapeString(allAttributes[name]))
+	}
+	b.WriteString(`></iframe>`)
+	return b.String()
+}
+
+// isSafeAttributeName reports whether name is a well-formed attribute name that cannot
+// inject scripts or replace the widget URL.
+func isSafeAttributeName(name string) bool {
+	if name == "" || name == "src" || name == "srcdoc" || strings.HasPrefix(name, "on") {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == ':') {
+			return false
+		}
+	}
+	return true
 }
 
+// GetUrl returns the signed widget URL, or an empty string if the widget cannot be signed,
+// e.g. because of an unknown sign_version. Unlike URL, it does not validate the widget.
 func (w *Widget) GetUrl() string {
-	return baseUrl + "/" + w.buildController() + "?" + w.getParams().Encode()
+	params, err := w.GetParams()
+	if err != nil {
+		return ""
+	}
+	return w.baseUrl + "/" + w.buildController() + "?" + params.Encode()
 }
 
 func (w *Widget) getDefaultWidgetSignature() string {
@@ -154,20 +253,21 @@ func (w *Widget) mergeExtraParams(params url.Values) {
 	}
 }
 
-func (w *Widget) getParams() url.Values {
+// GetParams returns the parameters of the widget call, signed unless skipSignature is set.
+func (w *Widget) GetParams() (url.Values, error) {
 	params := url.Values{}
 	params.Set("key", w.appKey)
 	params.Set("uid", w.uid)
 	params.Set("widget", w.code)
 	params.Set("email", w.email)
-	params.Set("timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	params.Set("timestamp", strconv.FormatInt(w.clock().Unix(), 10))
 	params.Set("ps", w.ps)
 
 	if len(w.products) > 0 {
 		if w.apiType == API_GOODS {
 			product := w.products[0]
 			params.Set("amount", product.DisplayAmount())
-			params.Set("currencyCode", product.Currency)
+			params.Set("currencyCode", product.Amount.Currency)
 			params.Set("ag_name", product.Name)
 			params.Set("ag_external_id", product.Identity)
 			params.Set("ag_type", string(product.Type))
@@ -176,17 +276,30 @@ func (w *Widget) getParams() url.Values {
 				params.Set("ag_period_type", string(product.PeriodType))
 				if product.Recurring {
 					params.Set("ag_recurring", "1")
-					// TODO: add trial product
+					if trial := product.Trial; trial != nil {
+						// The trial is charged first, the product takes over after it.
+						params.Set("amount", trial.DisplayAmount())
+						params.Set("currencyCode", trial.Amount.Currency)
+						params.Set("ag_period_length", trial.DisplayPeriodLength())
+						params.Set("ag_period_type", string(trial.PeriodType))
+						params.Set("ag_trial", "1")
+						params.Set("ag_post_trial_name", product.Name)
+						params.Set("ag_post_trial_external_id", product.Identity)
+						params.Set("ag_post_trial_period_length", product.DisplayPeriodLength())
+						params.Set("ag_post_trial_period_type", string(product.PeriodType))
+						params.Set("post_trial_amount", product.DisplayAmount())
+						params.Set("post_trial_currencyCode", product.Amount.Currency)
+					}
 				}
 			}
 		} else if w.apiType == API_CART {
 			for i, product := range w.products {
 				params.Set(fmt.Sprintf("external_ids[%d]", i), product.Identity)
-				if product.Amount > 0 {
+				if product.Amount.Amount > 0 {
 					params.Set(fmt.Sprintf("prices[%d]", i), product.DisplayAmount())
 				}
-				if product.Currency != "" {
-					params.Set(fmt.Sprintf("currencies[%d]", i), product.Currency)
+				if product.Amount.Currency != "" {
+					params.Set(fmt.Sprintf("currencies[%d]", i), product.Amount.Currency)
 				}
 			}
 		}
@@ -197,32 +310,18 @@ func (w *Widget) getParams() url.Values {
 	if !w.skipSignature {
 		signVersion := w.mergeSignVersion()
 		params.Set("sign_version", signVersion)
-		params.Set("sign", w.calculateSignature(params, signVersion))
+		sign, err := w.calculateSignature(params, signVersion)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("sign", sign)
 	} else {
 		params.Del("sign_version")
 	}
-	return params
+	return params, nil
 }
 
-func (w *Widget) calculateSignature(params url.Values, signVersion string) string {
-	keys := make([]string, 0, len(params))
-	for k := range params {
-		keys = append(keys, k)
-	}
-
-	var h hash.Hash
-	if signVersion == SignVersion3 {
-		h = sha256.New()
-	} else {
-		h = md5.New()
-	}
-
-	sort.Strings(keys)
-	baseString := ""
-	for _, k := range keys {
-		baseString += fmt.Sprintf("%s=%s", k, params.Get(k))
-	}
-	baseString += w.secretKey
-	h.Write([]byte(baseString))
-	return hex.EncodeToString(h.Sum(nil))
+func (w *Widget) calculateSignature(params url.Values, signVersion string) (string, error) {
+	signer := Signer{SecretKey: w.secretKey, Version: signVersion}
+	return signer.Sign(params)
 }
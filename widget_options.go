@@ -0,0 +1,86 @@
+package paymentwall
+
+import "time"
+
+// WidgetOption configures a Widget created by NewWidget.
+type WidgetOption func(w *Widget) error
+
+// WithUID sets the ID of the user in the application, which pingbacks report as uid.
+func WithUID(uid string) WidgetOption {
+	return func(w *Widget) error {
+		w.uid = uid
+		return nil
+	}
+}
+
+func WithEmail(email string) WidgetOption {
+	return func(w *Widget) error {
+		w.email = email
+		return nil
+	}
+}
+
+// WithPaymentSystem preselects a payment system, "all" by default.
+func WithPaymentSystem(ps string) WidgetOption {
+	return func(w *Widget) error {
+		w.SetPS(ps)
+		return nil
+	}
+}
+
+// WithSuccessURL sets the URL the user is redirected to after a successful payment.
+func WithSuccessURL(successUrl string) WidgetOption {
+	return WithExtra("success_url", successUrl)
+}
+
+// WithFailureURL sets the URL the user is redirected to after a failed payment.
+func WithFailureURL(failureUrl string) WidgetOption {
+	return WithExtra("failure_url", failureUrl)
+}
+
+// WithLanguage sets the language of the widget, as an ISO 639-1 code such as "en".
+func WithLanguage(lang string) WidgetOption {
+	return WithExtra("lang", lang)
+}
+
+// WithSignVersion sets the version of the widget signature.
+func WithSignVersion(signVersion string) WidgetOption {
+	return func(w *Widget) error {
+		if !isSignVersionSupported(signVersion) {
+			return ErrorUnknownSignVersion
+		}
+		w.SetExtraParam("sign_version", signVersion)
+		return nil
+	}
+}
+
+// WithExtra sets an extra parameter of the widget call. The parameters set from the
+// other options and the products are reserved, see Widget.Validate.
+func WithExtra(k, v string) WidgetOption {
+	return func(w *Widget) error {
+		w.SetExtraParam(k, v)
+		return nil
+	}
+}
+
+func WithProducts(products ...Product) WidgetOption {
+	return func(w *Widget) error {
+		return w.AppendProduct(products...)
+	}
+}
+
+// WithBaseURL sets the URL the widget calls are sent to, see Widget.SetBaseURL.
+func WithBaseURL(baseUrl string) WidgetOption {
+	return func(w *Widget) error {
+		w.SetBaseURL(baseUrl)
+		return nil
+	}
+}
+
+// WithClock sets the function returning the time of the timestamp parameter.
+func WithClock(clock func() time.Time) WidgetOption {
+	return func(w *Widget) error {
+		w.SetClock(clock)
+		return nil
+	}
+}
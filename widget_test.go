@@ -0,0 +1,274 @@
+package paymentwall
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestWidget(t *testing.T, apiType ApiType, opts ...WidgetOption) *Widget {
+	config := Config{AppKey: "app", SecretKey: "secret", ApiType: apiType, WidgetCode: "p1"}
+	w, err := NewWidget(config, append([]WidgetOption{WithUID("user")}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestNewWidget(t *testing.T) {
+	config := Config{AppKey: "app", SecretKey: "secret", ApiType: API_GOODS, WidgetCode: "p1"}
+	product := NewProduct("Gold", "gold", MustParseMoney("9.99", "USD"), ProductTypeFixed)
+
+	var tests = []struct {
+		config Config
+		opts   []WidgetOption
+		err    error
+	}{
+		{config, []WidgetOption{WithUID("user")}, nil},
+		{config, nil, ErrorMissingUID},
+		{Config{SecretKey: "secret", ApiType: API_VC, WidgetCode: "p1"}, []WidgetOption{WithUID("user")}, ErrorMissingAppKey},
+		{Config{AppKey: "app", ApiType: API_VC, WidgetCode: "p1"}, []WidgetOption{WithUID("user")}, ErrorMissingSecretKey},
+		{Config{AppKey: "app", ApiType: API_VC, WidgetCode: "p1", SkipSignature: true}, []WidgetOption{WithUID("user")}, nil},
+		{Config{AppKey: "app", SecretKey: "secret", WidgetCode: "p1"}, []WidgetOption{WithUID("user")}, ErrorInvalidApiType},
+		{Config{AppKey: "app", SecretKey: "secret", ApiType: API_VC}, []WidgetOption{WithUID("user")}, ErrorMissingWidgetCode},
+		{config, []WidgetOption{WithUID("user"), WithSignVersion("4")}, ErrorUnknownSignVersion},
+		{config, []WidgetOption{WithUID("user"), WithProducts(*product, *product)}, ErrorOnlyOneProductAllowed},
+	}
+	for i, test := range tests {
+		if _, err := NewWidget(test.config, test.opts...); err != test.err {
+			t.Errorf("%d: got %v, want %v", i, err, test.err)
+		}
+	}
+
+	w, err := NewWidget(config,
+		WithUID("user"),
+		WithEmail("user@example.com"),
+		WithPaymentSystem("cc"),
+		WithSuccessURL("https://example.com/success"),
+		WithLanguage("de"),
+		WithSignVersion(SignVersion2),
+		WithExtra("evaluation", "1"),
+		WithProducts(*product))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err := w.GetParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"uid":            "user",
+		"widget":         "p1",
+		"email":          "user@example.com",
+		"ps":             "cc",
+		"success_url":    "https://example.com/success",
+		"lang":           "de",
+		"sign_version":   "2",
+		"evaluation":     "1",
+		"ag_external_id": "gold",
+	}
+	for k, v := range want {
+		if params.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, params.Get(k), v)
+		}
+	}
+}
+
+func TestWidget_SignVersion(t *testing.T) {
+	w := newTestWidget(t, API_VC)
+	w.SetExtraParam("sign_version", SignVersion1)
+	u, err := url.Parse(w.GetUrl())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := md5.Sum([]byte("usersecret"))
+	if sign := u.Query().Get("sign"); sign != hex.EncodeToString(sum[:]) {
+		t.Errorf("got sign %s, want md5 of uid and secret key", sign)
+	}
+
+	w.SetExtraParam("sign_version", "4")
+	if u := w.GetUrl(); u != "" {
+		t.Errorf("got %s, want empty URL for unknown sign version", u)
+	}
+}
+
+func TestWidget_Trial(t *testing.T) {
+	product := NewProduct("Monthly", "monthly", MustParseMoney("9.99", "USD"), ProductTypeSubscription)
+	product.SetSubscription(1, PeriodTypeMonth, true)
+	product.SetTrial(MustParseMoney("0.99", "USD"), 7, PeriodTypeDay)
+
+	w := newTestWidget(t, API_GOODS)
+	if err := w.AppendProduct(*product); err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(w.GetUrl())
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := u.Query()
+
+	want := map[string]string{
+		"amount":                      "0.99",
+		"currencyCode":                "USD",
+		"ag_period_length":            "7",
+		"ag_period_type":              "day",
+		"ag_recurring":                "1",
+		"ag_trial":                    "1",
+		"ag_post_trial_name":          "Monthly",
+		"ag_post_trial_external_id":   "monthly",
+		"ag_post_trial_period_length": "1",
+		"ag_post_trial_period_type":   "month",
+		"post_trial_amount":           "9.99",
+		"post_trial_currencyCode":     "USD",
+	}
+	for k, v := range want {
+		if params.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, params.Get(k), v)
+		}
+	}
+
+	sign := params.Get("sign")
+	params.Del("sign")
+	if err := Verify(params, sign, "secret", params.Get("sign_version")); err != nil {
+		t.Errorf("trial parameters are not signed: %v", err)
+	}
+
+	// Paymentwall only takes trials of recurring subscriptions.
+	product.Recurring = false
+	w = newTestWidget(t, API_GOODS)
+	if err := w.AppendProduct(*product); err != nil {
+		t.Fatal(err)
+	}
+	params, err = w.GetParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Get("ag_trial") != "" || params.Get("amount") != "9.99" {
+		t.Errorf("non-recurring product has trial parameters: %v", params)
+	}
+}
+
+func TestWidget_SetTimestamp(t *testing.T) {
+	w := newTestWidget(t, API_VC)
+	w.SetTimestamp(time.Unix(1500000000, 0))
+
+	params, err := w.GetParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := params.Get("timestamp"); ts != "1500000000" {
+		t.Errorf("got timestamp %s, want 1500000000", ts)
+	}
+
+	want := "https://api.paymentwall.com/api/ps?email=&key=app&ps=all&sign=" +
+		"f87ba103855eb65ad81d9ee416171e426cae2766017f16456eadca567990fc81" +
+		"&sign_version=3&timestamp=1500000000&uid=user&widget=p1"
+	if u := w.GetUrl(); u != want {
+		t.Errorf("got %s, want %s", u, want)
+	}
+	if w.GetUrl() != w.GetUrl() {
+		t.Error("widget URL is not deterministic")
+	}
+}
+
+func TestWidget_Validate(t *testing.T) {
+	fixed := func(amount int64, currency string) Product {
+		return *NewProduct("Gold", "gold", NewMoney(amount, currency), ProductTypeFixed)
+	}
+	subscription := func(length uint, period PeriodType) Product {
+		p := NewProduct("Monthly", "monthly", MustParseMoney("9.99", "USD"), ProductTypeSubscription)
+		p.SetSubscription(length, period, true)
+		return *p
+	}
+	trial := subscription(1, PeriodTypeMonth)
+	trial.SetTrial(NewMoney(99, "usd"), 7, PeriodTypeDay)
+	nonRecurringTrial := subscription(1, PeriodTypeMonth)
+	nonRecurringTrial.Recurring = false
+	nonRecurringTrial.SetTrial(NewMoney(99, "USD"), 7, PeriodTypeDay)
+
+	var tests = []struct {
+		apiType  ApiType
+		products []Product
+		extra    map[string]string
+		err      error
+	}{
+		{API_VC, nil, nil, nil},
+		{API_GOODS, []Product{fixed(999, "USD")}, nil, nil},
+		{API_GOODS, []Product{subscription(1, PeriodTypeMonth)}, nil, nil},
+		{API_GOODS, nil, nil, ErrorMissingProduct},
+		{API_GOODS, []Product{fixed(-100, "USD")}, nil, ErrorInvalidProduct},
+		{API_GOODS, []Product{fixed(0, "USD")}, nil, ErrorInvalidProduct},
+		{API_GOODS, []Product{fixed(999, "")}, nil, ErrorInvalidCurrency},
+		{API_GOODS, []Product{fixed(999, "XYZ")}, nil, ErrorInvalidCurrency},
+		{API_GOODS, []Product{subscription(0, PeriodTypeMonth)}, nil, ErrorInvalidProduct},
+		{API_GOODS, []Product{subscription(1, "fortnight")}, nil, ErrorInvalidProduct},
+		{API_GOODS, []Product{trial}, nil, ErrorInvalidCurrency},
+		{API_GOODS, []Product{nonRecurringTrial}, nil, ErrorInvalidProduct},
+		{API_CART, nil, nil, ErrorMissingProduct},
+		{API_CART, []Product{fixed(0, ""), fixed(999, "EUR")}, nil, nil},
+		{API_CART, []Product{fixed(999, "eur")}, nil, ErrorInvalidCurrency},
+		{API_VC, nil, map[string]string{"sign": "x"}, ErrorReservedParam},
+		{API_VC, nil, map[string]string{"key": "x"}, ErrorReservedParam},
+		{API_VC, nil, map[string]string{"ps": "cc"}, ErrorReservedParam},
+		{API_VC, nil, map[string]string{"email": "x@example.com"}, ErrorReservedParam},
+		{API_GOODS, []Product{fixed(999, "USD")}, map[string]string{"amount": "-5"}, ErrorReservedParam},
+		{API_GOODS, []Product{fixed(999, "USD")}, map[string]string{"currencyCode": "EUR"}, ErrorReservedParam},
+		{API_GOODS, []Product{subscription(1, PeriodTypeMonth)}, map[string]string{"ag_period_type": "day"}, ErrorReservedParam},
+		{API_GOODS, []Product{subscription(1, PeriodTypeMonth)}, map[string]string{"ag_trial": "1"}, ErrorReservedParam},
+		{API_GOODS, []Product{subscription(1, PeriodTypeMonth)}, map[string]string{"post_trial_amount": "0"}, ErrorReservedParam},
+		{API_CART, []Product{fixed(999, "EUR")}, map[string]string{"prices[0]": "0.01"}, ErrorReservedParam},
+		{API_CART, []Product{fixed(999, "EUR")}, map[string]string{"external_ids[1]": "x"}, ErrorReservedParam},
+		{API_CART, []Product{fixed(999, "EUR")}, map[string]string{"currencies[7]": "USD"}, ErrorReservedParam},
+		{API_CART, []Product{fixed(999, "EUR")}, map[string]string{"prices[]": "1"}, ErrorReservedParam},
+		{API_CART, []Product{fixed(999, "EUR")}, map[string]string{"custom[0]": "x", "lang": "en"}, nil},
+		{API_VC, nil, map[string]string{"sign_version": "4"}, ErrorUnknownSignVersion},
+	}
+	for i, test := range tests {
+		w := newTestWidget(t, test.apiType)
+		w.products = test.products
+		w.SetExtraParams(test.extra)
+
+		u, err := w.URL()
+		if !errors.Is(err, test.err) {
+			t.Errorf("%d: got %v, want %v", i, err, test.err)
+		}
+		if (err == nil) == (u == "") {
+			t.Errorf("%d: got URL %q with error %v", i, u, err)
+		}
+	}
+}
+
+func TestWidget_GetHtmlCode(t *testing.T) {
+	w := newTestWidget(t, API_VC)
+	w.SetTimestamp(time.Unix(1500000000, 0))
+
+	html := w.GetHtmlCode(map[string]string{
+		"class":           `x" onload="alert(1)`,
+		"onload":          "alert(1)",
+		"src":             "https://evil.example.com",
+		`bad name"`:       "x",
+		"width":           "100%",
+		"allowfullscreen": "",
+	})
+	want := `<iframe src="https://api.paymentwall.com/api/ps?email=&amp;key=app&amp;ps=all&amp;sign=` +
+		`f87ba103855eb65ad81d9ee416171e426cae2766017f16456eadca567990fc81&amp;sign_version=3&amp;` +
+		`timestamp=1500000000&amp;uid=user&amp;widget=p1" allowfullscreen="" ` +
+		`class="x&#34; onload=&#34;alert(1)" frameborder="0" height="800" width="100%"></iframe>`
+	if html != want {
+		t.Errorf("got\n%s\nwant\n%s", html, want)
+	}
+	if html, err := w.GetHtml(nil); err != nil || string(html) != w.GetHtmlCode(nil) {
+		t.Errorf("GetHtml differs from GetHtmlCode: %v", err)
+	}
+
+	w.SetExtraParam("sign_version", "4")
+	if html, err := w.GetHtml(nil); err == nil || html != "" {
+		t.Errorf("got %q, %v for a widget that cannot be signed", html, err)
+	}
+	if html := w.GetHtmlCode(nil); html != "" {
+		t.Errorf("got %q for a widget that cannot be signed", html)
+	}
+}
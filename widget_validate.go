@@ -0,0 +1,149 @@
+package paymentwall
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrorMissingProduct  = errors.New("widget product is required")
+	ErrorInvalidProduct  = errors.New("invalid widget product")
+	ErrorReservedParam   = errors.New("extra parameter is reserved")
+	ErrorInvalidCurrency = errors.New("invalid ISO 4217 currency code")
+)
+
+// The parameters of a widget call that cannot be overridden with extra parameters,
+// as they are set by NewWidget, the options and setters, or from the products.
+var reservedWidgetParams = map[ApiType][]string{
+	API_VC: {"key", "sign", "uid", "widget", "timestamp", "ps", "email"},
+	API_GOODS: {"key", "sign", "uid", "widget", "timestamp", "ps", "email",
+		"amount", "currencyCode", "ag_name", "ag_external_id", "ag_type",
+		"ag_period_length", "ag_period_type", "ag_recurring", "ag_trial",
+		"ag_post_trial_name", "ag_post_trial_external_id",
+		"ag_post_trial_period_length", "ag_post_trial_period_type",
+		"post_trial_amount", "post_trial_currencyCode"},
+	API_CART: {"key", "sign", "uid", "widget", "timestamp", "ps", "email"},
+}
+
+// The array parameters of Cart API widget calls, set from the products, whose elements
+// such as prices[0] are reserved.
+var reservedCartArrayParams = []string{"external_ids", "prices", "currencies"}
+
+// isReservedParam reports whether the extra parameter k of a widget of apiType is reserved.
+func isReservedParam(apiType ApiType, k string) bool {
+	for _, reserved := range reservedWidgetParams[apiType] {
+		if k == reserved {
+			return true
+		}
+	}
+	if name, _, array := splitArrayKey(k); array && apiType == API_CART {
+		for _, reserved := range reservedCartArrayParams {
+			if name == reserved {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Validate checks the widget meets the requirements of its ApiType, so that Paymentwall
+// does not answer with an error page. The errors match ErrorMissingProduct,
+// ErrorInvalidProduct, ErrorInvalidCurrency, ErrorReservedParam and the errors of NewWidget.
+func (w *Widget) Validate() error {
+	if w.uid == "" {
+		return ErrorMissingUID
+	}
+
+	switch w.apiType {
+	case API_VC:
+	case API_GOODS:
+		if len(w.products) != 1 {
+			return ErrorMissingProduct
+		}
+		if err := validateProduct(&w.products[0], true); err != nil {
+			return err
+		}
+	case API_CART:
+		if len(w.products) == 0 {
+			return ErrorMissingProduct
+		}
+		for i := range w.products {
+			if err := validateProduct(&w.products[i], false); err != nil {
+				return err
+			}
+		}
+	default:
+		return ErrorInvalidApiType
+	}
+
+	for k := range w.extraParams {
+		if isReservedParam(w.apiType, k) {
+			return fmt.Errorf("%w: %s", ErrorReservedParam, k)
+		}
+	}
+	if !w.skipSignature && !isSignVersionSupported(w.mergeSignVersion()) {
+		return ErrorUnknownSignVersion
+	}
+	return nil
+}
+
+// validateProduct checks a product. The price is required for Digital Goods,
+// the Cart API takes it from the project settings when it is not set.
+func validateProduct(p *Product, priced bool) error {
+	if p.Identity == "" {
+		return fmt.Errorf("%w: missing identity", ErrorInvalidProduct)
+	}
+	if p.Amount.Amount < 0 || (priced && p.Amount.Amount == 0) {
+		return fmt.Errorf("%w %s: invalid amount %s", ErrorInvalidProduct, p.Identity, p.DisplayAmount())
+	}
+	if (priced || p.Amount.Currency != "") && !IsValidCurrency(p.Amount.Currency) {
+		return fmt.Errorf("%w: %q", ErrorInvalidCurrency, p.Amount.Currency)
+	}
+
+	if p.Type == ProductTypeSubscription {
+		if err := validatePeriod(p.PeriodLength, p.PeriodType); err != nil {
+			return fmt.Errorf("%w %s: %v", ErrorInvalidProduct, p.Identity, err)
+		}
+	} else if p.Type != ProductTypeFixed && p.Type != "" {
+		return fmt.Errorf("%w %s: invalid type %q", ErrorInvalidProduct, p.Identity, p.Type)
+	}
+
+	if t := p.Trial; t != nil {
+		if p.Type != ProductTypeSubscription || !p.Recurring {
+			return fmt.Errorf("%w %s: trial of a product that is not a recurring subscription", ErrorInvalidProduct, p.Identity)
+		}
+		if t.Amount.Amount < 0 {
+			return fmt.Errorf("%w %s: invalid trial amount %s", ErrorInvalidProduct, p.Identity, t.DisplayAmount())
+		}
+		if !IsValidCurrency(t.Amount.Currency) {
+			return fmt.Errorf("%w: %q", ErrorInvalidCurrency, t.Amount.Currency)
+		}
+		if err := validatePeriod(t.PeriodLength, t.PeriodType); err != nil {
+			return fmt.Errorf("%w %s: trial %v", ErrorInvalidProduct, p.Identity, err)
+		}
+	}
+	return nil
+}
+
+func validatePeriod(length uint, periodType PeriodType) error {
+	if length == 0 {
+		return errors.New("period length must be positive")
+	}
+	switch periodType {
+	case PeriodTypeDay, PeriodTypeWeek, PeriodTypeMonth, PeriodTypeYear:
+		return nil
+	}
+	return fmt.Errorf("invalid period type %q", periodType)
+}
+
+// URL validates the widget and returns the signed widget URL.
+func (w *Widget) URL() (string, error) {
+	if err := w.Validate(); err != nil {
+		return "", err
+	}
+	params, err := w.GetParams()
+	if err != nil {
+		return "", err
+	}
+	return w.baseUrl + "/" + w.buildController() + "?" + params.Encode(), nil
+}